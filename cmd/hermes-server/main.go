@@ -0,0 +1,69 @@
+// Command hermes-server serves hermes email rendering as a JSON API
+//
+// Usage:
+//
+//	hermes-server -addr :8080 -product-name Hermes -product-link https://example-hermes.com/
+//
+// Then:
+//
+//	curl -d '{"theme": "flat", "email": {"body": {"name": "Jon Snow", "intros": ["Welcome!"]}}}' localhost:8080/render
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/matcornic/hermes/v2"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "Address to listen on")
+	theme := flag.String("theme", "default", "Default theme of emails")
+	maxRequestSize := flag.Int64("max-request-size", hermes.DefaultMaxRequestSize, "Maximum size of a request body, in bytes")
+	var product hermes.Product
+	flag.StringVar(&product.Name, "product-name", "", "Default product name")
+	flag.StringVar(&product.Link, "product-link", "", "Default product link")
+	flag.StringVar(&product.Logo, "product-logo", "", "Default product logo URL")
+	flag.StringVar(&product.Copyright, "product-copyright", "", "Default product copyright")
+	flag.Parse()
+
+	t, err := hermes.ThemeByName(*theme)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := hermes.NewServer(hermes.Hermes{Theme: t, Product: product})
+	server.MaxRequestSize = *maxRequestSize
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      time.Minute,
+	}
+	done := make(chan struct{})
+	go func() {
+		// Stop gracefully on interruption, letting pending requests finish
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		close(done)
+	}()
+
+	log.Printf("hermes-server listening on %s", *addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+}
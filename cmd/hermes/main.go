@@ -0,0 +1,255 @@
+// Command hermes renders emails described in JSON or YAML files, without writing Go code
+//
+// Usage:
+//
+//	hermes render [-theme name] [-format html|text|eml] [-o output] [file]
+//	hermes themes
+//	hermes lint file...
+//	hermes preview [-addr host:port] [-theme name] [-theme-dir dir] file
+//
+// Files describe a RenderRequest, e.g. in YAML:
+//
+//	theme: flat
+//	product:
+//	  name: Hermes
+//	  link: https://example-hermes.com/
+//	email:
+//	  body:
+//	    name: Jon Snow
+//	    intros:
+//	      - Welcome to Hermes! We're very excited to have you on board.
+//
+// Markdown files, with the .md or .markdown extension, describe the email with a YAML front matter, their content being its free markdown:
+//
+//	---
+//	name: Jon Snow
+//	product:
+//	  name: Hermes
+//	---
+//	**Winter** is coming
+//
+// The request is read from the standard input when no file is given or the file is "-".
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/matcornic/hermes/v2"
+)
+
+const usage = `Usage:
+  hermes render [-theme name] [-format html|text|eml] [-o output] [file]
+  hermes themes
+  hermes lint file...
+  hermes preview [-addr host:port] [-theme name] [-theme-dir dir] file
+`
+
+// Errors returned when they were already reported
+var (
+	errInvalid = errors.New("invalid request")
+	errUsage   = errors.New("invalid usage")
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command with the given arguments and returns its exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var err error
+	switch args[0] {
+	case "render":
+		err = render(args[1:], stdin, stdout, stderr)
+	case "themes":
+		for _, t := range hermes.Themes() {
+			fmt.Fprintln(stdout, t.Name())
+		}
+	case "lint":
+		err = lint(args[1:], stdin, stdout)
+	case "preview":
+		err = previewCommand(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+	default:
+		fmt.Fprintf(stderr, "hermes: unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+	if err == errUsage {
+		return 2
+	}
+	if err != nil {
+		if err != errInvalid {
+			fmt.Fprintf(stderr, "hermes: %s\n", strings.TrimPrefix(err.Error(), "hermes: "))
+		}
+		return 1
+	}
+	return 0
+}
+
+func render(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	theme := flags.String("theme", "", "Theme of the email, overriding the one of the file")
+	format := flags.String("format", "", "Output format: html, text or eml (default to the output extension, then to the one of the file)")
+	output := flags.String("o", "", "Output file (default to the standard output)")
+	err := flags.Parse(args)
+	if err != nil {
+		// Flag errors and usage are reported by the flag set
+		return errUsage
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("render takes a single file, got %d", flags.NArg())
+	}
+
+	name := flags.Arg(0)
+	req, err := readRequest(name, stdin)
+	if err != nil {
+		return err
+	}
+	if *theme != "" {
+		req.Theme = *theme
+	}
+	if *format != "" {
+		req.Format = *format
+	} else if ext := strings.TrimPrefix(filepath.Ext(*output), "."); ext != "" {
+		switch ext {
+		case "htm":
+			req.Format = hermes.FormatHTML
+		case "txt":
+			req.Format = hermes.FormatPlainText
+		default:
+			req.Format = ext
+		}
+	}
+
+	h, err := req.Configure(hermes.Hermes{}, hermes.Themes())
+	if errs, ok := err.(hermes.ValidationErrors); ok {
+		reportErrors(stderr, displayName(name), errs)
+		return errInvalid
+	}
+	if err != nil {
+		return err
+	}
+	h.ContrastPolicy = hermes.ContrastWarn
+	content, warnings, err := generate(h, req)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(stderr, "%s: warning: %s: %s\n", displayName(name), w.Field, w.Message)
+	}
+	if *output == "" {
+		_, err = stdout.Write(content)
+		return err
+	}
+	return ioutil.WriteFile(*output, content, 0644)
+}
+
+// generate renders the request in its format, along with the warnings of the rendering
+func generate(h hermes.Hermes, req hermes.RenderRequest) ([]byte, hermes.ValidationErrors, error) {
+	rendered, err := h.Generate(req.Email)
+	if errs, ok := err.(hermes.ValidationErrors); ok {
+		return nil, nil, requestErrors(errs)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings := requestErrors(rendered.Warnings)
+	switch req.Format {
+	case hermes.FormatPlainText:
+		return []byte(rendered.Text), warnings, nil
+	case hermes.FormatMessage:
+		var b bytes.Buffer
+		_, err = req.Message.Message(rendered).WriteTo(&b)
+		return b.Bytes(), warnings, err
+	default:
+		return []byte(rendered.HTML), warnings, nil
+	}
+}
+
+// requestErrors names the fields of errors found while rendering after the fields of the request
+func requestErrors(errs hermes.ValidationErrors) hermes.ValidationErrors {
+	var named hermes.ValidationErrors
+	for _, e := range errs {
+		field := e.Field
+		if strings.HasPrefix(field, "body.") {
+			field = "email." + field
+		}
+		named = append(named, &hermes.ValidationError{Field: field, Message: e.Message})
+	}
+	return named
+}
+
+func lint(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	valid := true
+	for _, name := range args {
+		req, err := readRequest(name, stdin)
+		if err == nil {
+			var h hermes.Hermes
+			h, err = req.Configure(hermes.Hermes{}, hermes.Themes())
+			if err == nil {
+				// Some errors, e.g. in inline images or unreadable colors, are only detected while rendering
+				h.ContrastPolicy = hermes.ContrastEnforce
+				_, _, err = generate(h, req)
+			}
+		}
+		if err == nil {
+			continue
+		}
+		valid = false
+		if errs, ok := err.(hermes.ValidationErrors); ok {
+			reportErrors(stdout, displayName(name), errs)
+		} else {
+			fmt.Fprintf(stdout, "%s: %s\n", displayName(name), strings.TrimPrefix(err.Error(), "hermes: "))
+		}
+	}
+	if !valid {
+		return errInvalid
+	}
+	return nil
+}
+
+// readRequest reads a request from a file, or from stdin when name is empty or "-"
+// Markdown files, with the .md or .markdown extension, are read with their front matter.
+func readRequest(name string, stdin io.Reader) (hermes.RenderRequest, error) {
+	if name == "" || name == "-" {
+		return hermes.ReadRenderRequest(stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return hermes.RenderRequest{}, err
+	}
+	defer f.Close()
+	if ext := filepath.Ext(name); ext == ".md" || ext == ".markdown" {
+		return hermes.ReadMarkdownRequest(f)
+	}
+	return hermes.ReadRenderRequest(f)
+}
+
+func reportErrors(w io.Writer, name string, errs hermes.ValidationErrors) {
+	for _, e := range errs {
+		fmt.Fprintf(w, "%s: %s: %s\n", name, e.Field, e.Message)
+	}
+}
+
+func displayName(name string) string {
+	if name == "" || name == "-" {
+		return "<stdin>"
+	}
+	return name
+}
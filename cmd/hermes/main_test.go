@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runTest(args []string, stdin string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRender(t *testing.T) {
+	code, stdout, stderr := runTest([]string{"render", "testdata/welcome.yaml"}, "")
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "<html")
+	assert.Contains(t, stdout, "Jon Snow")
+
+	code, stdout, _ = runTest([]string{"render", "-format", "text", "testdata/welcome.yaml"}, "")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Hi Jon Snow,")
+	assert.NotContains(t, stdout, "<html")
+
+	code, stdout, _ = runTest([]string{"render", "-theme", "default", "-format", "text", "-"}, `{"email": {"body": {"name": "Arya"}}}`)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Hi Arya,", "Request should be read from stdin")
+
+	code, stdout, stderr = runTest([]string{"render", "-format", "text", "testdata/welcome.md"}, "")
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "Hi Jon Snow,")
+	assert.Contains(t, stdout, "Hermes will be *unavailable* on Sunday", "Markdown files should be read with their front matter")
+}
+
+func TestRenderToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hermes")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for ext, expected := range map[string]string{
+		".html": "<html",
+		".txt":  "Hi Jon Snow,",
+		".eml":  "Subject: Welcome to Hermes",
+	} {
+		output := filepath.Join(dir, "welcome"+ext)
+		code, stdout, stderr := runTest([]string{"render", "-o", output, "testdata/welcome.yaml"}, "")
+		assert.Equal(t, 0, code, stderr)
+		assert.Empty(t, stdout)
+		content, err := ioutil.ReadFile(output)
+		assert.Nil(t, err)
+		assert.Contains(t, string(content), expected, "Format should be chosen from the %s extension", ext)
+	}
+}
+
+func TestRenderInvalid(t *testing.T) {
+	code, _, stderr := runTest([]string{"render", "testdata/invalid.json"}, "")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "testdata/invalid.json: theme: is not one of the available themes\n"+
+		"testdata/invalid.json: email.body.actions[0].button.link: is required\n", stderr)
+
+	code, _, stderr = runTest([]string{"render", "testdata/missing.yaml"}, "")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "missing.yaml")
+
+	code, _, _ = runTest([]string{"render", "-unknown"}, "")
+	assert.Equal(t, 2, code)
+}
+
+func TestLint(t *testing.T) {
+	code, stdout, _ := runTest([]string{"lint", "testdata/welcome.yaml"}, "")
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stdout)
+
+	code, stdout, _ = runTest([]string{"lint", "testdata/welcome.yaml", "testdata/invalid.json", "-"}, `email: {body: {images: [{name: notes.txt, content: V2ludGVy}]}}`)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "testdata/invalid.json: theme: is not one of the available themes\n"+
+		"testdata/invalid.json: email.body.actions[0].button.link: is required\n"+
+		"<stdin>: email.body.images[0]: inline image \"notes.txt\" is not an image but text/plain; charset=utf-8\n", stdout)
+}
+
+func TestContrast(t *testing.T) {
+	unreadable := `email: {body: {actions: [{button: {text: Confirm, link: "https://hermes-example.com/", color: "#FFEB3B", textColor: "#FFFFFF"}}]}}`
+	code, stdout, stderr := runTest([]string{"render", "-"}, unreadable)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "<html")
+	assert.Equal(t, "<stdin>: warning: email.body.actions[0].button.textColor: contrast ratio of #FFFFFF on #FFEB3B is 1.22:1, below 4.5:1\n", stderr)
+
+	code, stdout, _ = runTest([]string{"lint", "-"}, unreadable)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "<stdin>: email.body.actions[0].button.textColor: contrast ratio of #FFFFFF on #FFEB3B is 1.22:1, below 4.5:1\n", stdout)
+}
+
+func TestThemes(t *testing.T) {
+	code, stdout, _ := runTest([]string{"themes"}, "")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "default\nflat\n", stdout)
+
+	code, _, stderr := runTest([]string{"unknown"}, "")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "Usage")
+}
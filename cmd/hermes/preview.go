@@ -0,0 +1,362 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matcornic/hermes/v2"
+)
+
+func previewCommand(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("preview", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", "localhost:8080", "Address to listen on")
+	theme := flags.String("theme", "", "Theme of the email, overriding the one of the file")
+	themeDir := flags.String("theme-dir", "", "Directory of a theme under development, described by its "+hermes.ThemeManifestFile+" manifest")
+	interval := flags.Duration("interval", 500*time.Millisecond, "Interval between checks for changes")
+	err := flags.Parse(args)
+	if err != nil {
+		return errUsage
+	}
+	if flags.NArg() != 1 || flags.Arg(0) == "-" {
+		fmt.Fprintln(stderr, "preview takes a single file, which is watched for changes")
+		return errUsage
+	}
+
+	p := newPreview(flags.Arg(0), *themeDir, *theme)
+	p.refresh()
+	go func() {
+		for range time.Tick(*interval) {
+			p.refresh()
+		}
+	}()
+	fmt.Fprintf(stdout, "Previewing %s on http://%s\n", flags.Arg(0), *addr)
+	return http.ListenAndServe(*addr, p)
+}
+
+// preview renders a content file each time it, or the theme directory, changes
+type preview struct {
+	file     string
+	themeDir string
+	theme    string
+
+	mu          sync.Mutex
+	fingerprint string
+	version     int
+	result      previewResult
+}
+
+// previewResult are the outputs of a rendering
+type previewResult struct {
+	Inlined  string // HTML with inlined CSS, as sent
+	Original string // HTML before CSS inlining
+	Text     string
+	Errors   []previewError
+}
+
+// previewError is a render error, with an excerpt of the template when its line is known
+type previewError struct {
+	Source  string
+	Message string
+	Excerpt []excerptLine
+}
+
+type excerptLine struct {
+	Number int
+	Text   string
+	Error  bool
+}
+
+func newPreview(file, themeDir, theme string) *preview {
+	return &preview{file: file, themeDir: themeDir, theme: theme}
+}
+
+// refresh renders the email again when files changed, and reports whether they did
+func (p *preview) refresh() bool {
+	fingerprint := p.fingerprintFiles()
+	p.mu.Lock()
+	changed := fingerprint != p.fingerprint
+	p.mu.Unlock()
+	if !changed {
+		return false
+	}
+	result := p.render()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fingerprint = fingerprint
+	p.version++
+	p.result = result
+	return true
+}
+
+// fingerprintFiles identifies the current state of watched files by their modification times and sizes
+func (p *preview) fingerprintFiles() string {
+	var b strings.Builder
+	add := func(path string, info os.FileInfo) {
+		fmt.Fprintf(&b, "%s:%d:%d\n", path, info.ModTime().UnixNano(), info.Size())
+	}
+	if info, err := os.Stat(p.file); err == nil {
+		add(p.file, info)
+	}
+	if p.themeDir != "" {
+		filepath.Walk(p.themeDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				add(path, info)
+			}
+			return nil
+		})
+	}
+	return b.String()
+}
+
+func (p *preview) render() previewResult {
+	var result previewResult
+	fail := func(source string, err error) previewResult {
+		result.Errors = append(result.Errors, previewError{Source: source, Message: strings.TrimPrefix(err.Error(), "hermes: ")})
+		return result
+	}
+
+	req, err := readRequest(p.file, nil)
+	if err != nil {
+		return fail(p.file, err)
+	}
+	themes := hermes.Themes()
+	if p.themeDir != "" {
+		t, err := loadThemeDir(p.themeDir)
+		var themeErr *hermes.ThemeError
+		if errors.As(err, &themeErr) && themeErr.File != "" {
+			source := filepath.Join(p.themeDir, filepath.FromSlash(themeErr.File))
+			content, _ := ioutil.ReadFile(source)
+			result.Errors = append(result.Errors, newPreviewError(source, string(content), themeErr.Err))
+			return result
+		}
+		if err != nil {
+			return fail(p.themeDir, err)
+		}
+		themes = append(themes, t)
+		req.Theme = t.Name()
+	}
+	if p.theme != "" {
+		req.Theme = p.theme
+	}
+	h, err := req.Configure(hermes.Hermes{}, themes)
+	if errs, ok := err.(hermes.ValidationErrors); ok {
+		for _, e := range errs {
+			fail(p.file, e)
+		}
+		return result
+	}
+	if err != nil {
+		return fail(p.file, err)
+	}
+	if h.Theme == nil {
+		h.Theme = new(hermes.Default)
+	}
+
+	// Each version only parses its own template, so that errors are reported on the right template
+	htmlSource, textSource := "HTML template of theme "+h.Theme.Name(), "plain text template of theme "+h.Theme.Name()
+	outputs := []struct {
+		output   *string
+		source   string
+		template string
+		generate func(h hermes.Hermes) (string, error)
+	}{
+		{&result.Inlined, htmlSource, h.Theme.HTMLTemplate(), func(h hermes.Hermes) (string, error) {
+			return h.GenerateHTML(req.Email)
+		}},
+		{&result.Original, htmlSource, h.Theme.HTMLTemplate(), func(h hermes.Hermes) (string, error) {
+			h.DisableCSSInlining = true
+			return h.GenerateHTML(req.Email)
+		}},
+		{&result.Text, textSource, h.Theme.PlainTextTemplate(), func(h hermes.Hermes) (string, error) {
+			return h.GeneratePlainText(req.Email)
+		}},
+	}
+	for i, o := range outputs {
+		out, err := o.generate(h)
+		if err != nil {
+			// The non inlined HTML fails the same way as the inlined one
+			if i == 0 || i == 2 {
+				result.Errors = append(result.Errors, newPreviewError(o.source, o.template, err))
+			}
+			continue
+		}
+		*o.output = out
+	}
+	return result
+}
+
+// templateErrorRegexp matches the line of parse and execution errors of main templates, e.g. `template: hermes:12:5: ...`
+// Errors in partial templates are named after the partial instead.
+var templateErrorRegexp = regexp.MustCompile(`template: hermes:(\d+)(:\d+)?:`)
+
+// excerptContext is the number of lines shown around the line of an error
+const excerptContext = 3
+
+func newPreviewError(source string, tplt string, err error) previewError {
+	e := previewError{Source: source, Message: strings.TrimPrefix(err.Error(), "hermes: ")}
+	match := templateErrorRegexp.FindStringSubmatch(e.Message)
+	if match == nil {
+		return e
+	}
+	line, _ := strconv.Atoi(match[1])
+	lines := strings.Split(tplt, "\n")
+	for n := line - excerptContext; n <= line+excerptContext; n++ {
+		if n >= 1 && n <= len(lines) {
+			e.Excerpt = append(e.Excerpt, excerptLine{Number: n, Text: lines[n-1], Error: n == line})
+		}
+	}
+	return e
+}
+
+// loadThemeDir loads the theme of a directory of the file system
+func loadThemeDir(dir string) (hermes.Theme, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return hermes.ThemeFromFS(os.DirFS(filepath.Dir(abs)), filepath.Base(abs))
+}
+
+func (p *preview) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	version, result := p.version, p.result
+	p.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := previewPage.Execute(w, map[string]interface{}{
+			"File":    p.file,
+			"Version": version,
+			"Result":  result,
+			"Widths":  previewWidths,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case "/html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.URL.Query().Get("css") == "original" {
+			io.WriteString(w, result.Original)
+		} else {
+			io.WriteString(w, result.Inlined)
+		}
+	case "/version":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(w, version)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// previewWidths are the widths at which the HTML email is displayed, in pixels
+var previewWidths = []struct {
+	Name  string
+	Width int
+}{
+	{"Desktop", 800},
+	{"Mobile", 375},
+}
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.File}} - hermes preview</title>
+  <style>
+    body { font-family: sans-serif; margin: 0; background: #F2F4F6; color: #333; }
+    header { padding: 10px 20px; background: #333; color: #FFF; display: flex; justify-content: space-between; align-items: center; }
+    header label { margin-left: 10px; }
+    main { padding: 20px; }
+    section { display: inline-block; vertical-align: top; margin: 0 20px 20px 0; }
+    h2 { font-size: 14px; text-transform: uppercase; color: #74787E; }
+    iframe { border: 1px solid #CCC; background: #FFF; height: 700px; }
+    pre { background: #FFF; border: 1px solid #CCC; padding: 10px; overflow: auto; }
+    .text pre { width: 600px; height: 680px; margin: 0; }
+    .sources { display: flex; }
+    .sources section { flex: 1; min-width: 0; }
+    .sources pre { max-height: 500px; }
+    .error { background: #FDECEA; border: 1px solid #F5C2C0; padding: 10px 20px; margin-bottom: 20px; }
+    .error pre { background: #FFF; }
+    .error .line { color: #B00; font-weight: bold; }
+  </style>
+</head>
+<body>
+<header>
+  <strong>{{.File}}</strong>
+  <form>
+    CSS:
+    <label><input type="radio" name="css" value="inlined" checked> inlined</label>
+    <label><input type="radio" name="css" value="original"> not inlined</label>
+  </form>
+</header>
+<main>
+  {{range .Result.Errors}}
+  <div class="error">
+    <p><strong>{{.Source}}</strong>: {{.Message}}</p>
+    {{if .Excerpt}}<pre>{{range .Excerpt}}<span{{if .Error}} class="line"{{end}}>{{printf "%4d" .Number}} | {{.Text}}</span>
+{{end}}</pre>{{end}}
+  </div>
+  {{end}}
+  {{range .Widths}}
+  <section>
+    <h2>{{.Name}} ({{.Width}}px)</h2>
+    <iframe class="html" src="/html?v={{$.Version}}" width="{{.Width}}"></iframe>
+  </section>
+  {{end}}
+  <section class="text">
+    <h2>Plain text</h2>
+    <pre>{{.Result.Text}}</pre>
+  </section>
+  <div class="sources">
+    <section>
+      <h2>HTML, CSS inlined</h2>
+      <pre>{{.Result.Inlined}}</pre>
+    </section>
+    <section>
+      <h2>HTML, CSS not inlined</h2>
+      <pre>{{.Result.Original}}</pre>
+    </section>
+  </div>
+</main>
+<script>
+  var version = "{{.Version}}";
+  var css = sessionStorage.getItem("css") || "inlined";
+  function showHTML() {
+    document.querySelectorAll("iframe.html").forEach(function (iframe) {
+      iframe.src = "/html?css=" + css + "&v=" + version;
+    });
+  }
+  document.querySelectorAll("input[name=css]").forEach(function (input) {
+    input.checked = input.value === css;
+    input.addEventListener("change", function () {
+      css = input.value;
+      sessionStorage.setItem("css", css);
+      showHTML();
+    });
+  });
+  showHTML();
+  // Reload when files changed
+  setInterval(function () {
+    fetch("/version").then(function (res) { return res.text(); }).then(function (v) {
+      if (v !== version) { location.reload(); }
+    }).catch(function () {});
+  }, 1000);
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const previewHTMLTemplate = `<html>
+<head><style>p { color: red; }</style></head>
+<body>
+<p>Hi {{ .Email.Body.Name }}</p>
+</body>
+</html>
+`
+
+func writeFile(t *testing.T, path string, content string) {
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	// Modification times may not change within a short period on some file systems
+	later := time.Now().Add(time.Duration(len(content)) * time.Second)
+	assert.Nil(t, os.Chtimes(path, later, later))
+}
+
+func setupPreview(t *testing.T) (*preview, string) {
+	dir, err := ioutil.TempDir("", "hermes")
+	assert.Nil(t, err)
+	themeDir := filepath.Join(dir, "mytheme")
+	assert.Nil(t, os.Mkdir(themeDir, 0755))
+	writeFile(t, filepath.Join(themeDir, "theme.yaml"), "name: mytheme")
+	writeFile(t, filepath.Join(themeDir, "html.tmpl"), previewHTMLTemplate)
+	writeFile(t, filepath.Join(themeDir, "text.tmpl"), `Hi {{ .Email.Body.Name }}`)
+	writeFile(t, filepath.Join(dir, "welcome.yaml"), "email: {body: {name: Jon Snow}}")
+	return newPreview(filepath.Join(dir, "welcome.yaml"), themeDir, ""), dir
+}
+
+func TestPreview_Refresh(t *testing.T) {
+	p, dir := setupPreview(t)
+	defer os.RemoveAll(dir)
+
+	assert.True(t, p.refresh())
+	assert.Empty(t, p.result.Errors)
+	assert.Contains(t, p.result.Inlined, `<p style="color:red">Hi Jon Snow</p>`)
+	assert.Contains(t, p.result.Original, `<p>Hi Jon Snow</p>`)
+	assert.Equal(t, "Hi Jon Snow", p.result.Text)
+	assert.False(t, p.refresh(), "Should not render again when files did not change")
+	assert.Equal(t, 1, p.version)
+
+	writeFile(t, p.file, "email: {body: {name: Arya Stark}}")
+	assert.True(t, p.refresh(), "Should render again when the content changed")
+	assert.Equal(t, "Hi Arya Stark", p.result.Text)
+
+	writeFile(t, filepath.Join(p.themeDir, "text.tmpl"), `Hello {{ .Email.Body.Name }}`)
+	assert.True(t, p.refresh(), "Should render again when the theme changed")
+	assert.Equal(t, "Hello Arya Stark", p.result.Text)
+	assert.Equal(t, 3, p.version)
+}
+
+func TestPreview_BundledTheme(t *testing.T) {
+	p := newPreview(filepath.Join("testdata", "welcome.yaml"), "", "")
+	assert.True(t, p.refresh())
+	assert.Empty(t, p.result.Errors)
+	assert.Contains(t, p.result.Original, ".button {", "Stylesheet of the theme should be included")
+	assert.Contains(t, p.result.Text, "Jon Snow")
+}
+
+func TestPreview_Errors(t *testing.T) {
+	p, dir := setupPreview(t)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(p.themeDir, "html.tmpl"), strings.Replace(previewHTMLTemplate, "{{ .Email.Body.Name }}", "{{ .Email.Body.Name ", 1))
+	p.refresh()
+	assert.Len(t, p.result.Errors, 1)
+	e := p.result.Errors[0]
+	assert.Equal(t, filepath.Join(p.themeDir, "html.tmpl"), e.Source)
+	assert.Contains(t, e.Message, ":4:")
+	assert.Equal(t, []excerptLine{
+		{Number: 1, Text: "<html>"},
+		{Number: 2, Text: "<head><style>p { color: red; }</style></head>"},
+		{Number: 3, Text: "<body>"},
+		{Number: 4, Text: "<p>Hi {{ .Email.Body.Name </p>", Error: true},
+		{Number: 5, Text: "</body>"},
+		{Number: 6, Text: "</html>"},
+		{Number: 7, Text: ""},
+	}, e.Excerpt)
+
+	writeFile(t, filepath.Join(p.themeDir, "html.tmpl"), previewHTMLTemplate+" ")
+	writeFile(t, p.file, "email: {body: {actions: [{button: {text: Confirm}}]}}")
+	p.refresh()
+	assert.Equal(t, "email.body.actions[0].button.link: is required", p.result.Errors[0].Message)
+}
+
+func TestPreview_ServeHTTP(t *testing.T) {
+	p, dir := setupPreview(t)
+	defer os.RemoveAll(dir)
+	p.refresh()
+
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		return w.Body.String()
+	}
+	page := get("/")
+	assert.Contains(t, page, `width="800"`)
+	assert.Contains(t, page, `width="375"`)
+	assert.Contains(t, page, "Hi Jon Snow")
+	assert.Contains(t, get("/html"), `style="color:red"`)
+	assert.NotContains(t, get("/html?css=original"), `style="color:red"`)
+	assert.Equal(t, "1", get("/version"))
+}
@@ -0,0 +1,427 @@
+package hermes
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MinContrastRatio is the minimum contrast ratio between a text and its background
+// required by the level AA of the Web Content Accessibility Guidelines, for normal text
+const MinContrastRatio = 4.5
+
+// Color is an RGB color
+type Color struct {
+	R, G, B uint8
+}
+
+// Colors used to derive palettes
+var (
+	White = Color{0xFF, 0xFF, 0xFF}
+	Black = Color{0x00, 0x00, 0x00}
+)
+
+// ParseColor parses a CSS color: hexadecimal, e.g. #3869D4 or #FFF, functional, e.g. rgb(56, 105, 212), or named, e.g. navy
+func ParseColor(s string) (Color, error) {
+	invalid := fmt.Errorf("hermes: invalid color %q: expected #RGB, #RRGGBB, rgb(r, g, b) or a color name", s)
+	value := strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[value]; ok {
+		return c, nil
+	}
+	if strings.HasPrefix(value, "rgb(") && strings.HasSuffix(value, ")") {
+		channels := strings.Split(strings.TrimSuffix(strings.TrimPrefix(value, "rgb("), ")"), ",")
+		if len(channels) != 3 {
+			return Color{}, invalid
+		}
+		var rgb [3]uint8
+		for i, channel := range channels {
+			v, err := strconv.ParseUint(strings.TrimSpace(channel), 10, 8)
+			if err != nil {
+				return Color{}, invalid
+			}
+			rgb[i] = uint8(v)
+		}
+		return Color{rgb[0], rgb[1], rgb[2]}, nil
+	}
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if !strings.HasPrefix(value, "#") || len(hex) != 6 {
+		return Color{}, invalid
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Color{}, invalid
+	}
+	return Color{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// validColor returns whether s can be used as a CSS color: parsed by ParseColor, or any other CSS value, e.g. rgba(0, 0, 0, 0.5),
+// hsl(150, 70%, 44%) or transparent, which is not checked for contrast
+// Hexadecimal colors must have 3, 4, 6 or 8 digits.
+func validColor(s string) bool {
+	if _, err := ParseColor(s); err == nil {
+		return true
+	}
+	value := strings.TrimSpace(s)
+	if hex := strings.TrimPrefix(value, "#"); hex != value {
+		_, err := strconv.ParseUint(hex, 16, 32)
+		return err == nil && (len(hex) == 4 || len(hex) == 8)
+	}
+	return value != "" && validCSSValue(value)
+}
+
+// Hex returns the hexadecimal notation of the color, e.g. #3869D4
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+func (c Color) String() string {
+	return c.Hex()
+}
+
+// Mix mixes the color with another one
+// Weight is the proportion of the other color, from 0 (c) to 1 (other).
+func (c Color) Mix(other Color, weight float64) Color {
+	weight = math.Max(0, math.Min(1, weight))
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a)*(1-weight) + float64(b)*weight))
+	}
+	return Color{mix(c.R, other.R), mix(c.G, other.G), mix(c.B, other.B)}
+}
+
+// Tint lightens the color by mixing it with white, from 0 (c) to 1 (white)
+func (c Color) Tint(weight float64) Color {
+	return c.Mix(White, weight)
+}
+
+// Shade darkens the color by mixing it with black, from 0 (c) to 1 (black)
+func (c Color) Shade(weight float64) Color {
+	return c.Mix(Black, weight)
+}
+
+// Luminance returns the relative luminance of the color, from 0 (black) to 1 (white)
+// https://www.w3.org/TR/WCAG21/#dfn-relative-luminance
+func (c Color) Luminance() float64 {
+	linear := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(c.R) + 0.7152*linear(c.G) + 0.0722*linear(c.B)
+}
+
+// ContrastRatio returns the contrast ratio between two colors, from 1 (same luminance) to 21 (black and white)
+// https://www.w3.org/TR/WCAG21/#dfn-contrast-ratio
+func ContrastRatio(a, b Color) float64 {
+	la, lb := a.Luminance(), b.Luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// ContrastingText returns white or black, whichever is the most readable on the color
+func (c Color) ContrastingText() Color {
+	if ContrastRatio(c, White) >= ContrastRatio(c, Black) {
+		return White
+	}
+	return Black
+}
+
+// readableOn returns the color, darkened or lightened until its contrast ratio with background reaches MinContrastRatio
+func (c Color) readableOn(background Color) Color {
+	target := Black
+	if background.ContrastingText() == White {
+		target = White
+	}
+	for weight := 0.0; weight < 1; weight += 0.05 {
+		if readable := c.Mix(target, weight); ContrastRatio(readable, background) >= MinContrastRatio {
+			return readable
+		}
+	}
+	return target
+}
+
+// ContrastPolicy is how hermes handles buttons whose colors are not readable enough,
+// i.e. whose contrast ratio is below MinContrastRatio
+type ContrastPolicy int
+
+const (
+	// ContrastIgnore does not check colors (default)
+	ContrastIgnore ContrastPolicy = iota
+	// ContrastWarn lists unreadable colors in Rendered.Warnings
+	// Only Generate and GenerateContext return them: GenerateHTML, GeneratePlainText and their To variants
+	// generate the email without reporting them, like ContrastIgnore.
+	ContrastWarn
+	// ContrastEnforce fails the generation of emails with unreadable colors
+	ContrastEnforce
+)
+
+// readableText returns a text color readable on background: text when readable enough, black or white otherwise
+// Colors that cannot be parsed are not checked.
+func readableText(background, text string) string {
+	bg, err := ParseColor(background)
+	if err != nil {
+		return text
+	}
+	if fg, err := ParseColor(text); err == nil && ContrastRatio(fg, bg) >= MinContrastRatio {
+		return text
+	}
+	return bg.ContrastingText().Hex()
+}
+
+// readableButtons returns a copy of actions where buttons with a color but no text color get a readable text color,
+// when the one of the theme is not readable enough
+// Valid colors are written in hex, e.g. rgb(34, 188, 102) as #22BC66, which templates output as CSS values as they are.
+func readableButtons(options ThemeOptions, actions []Action) []Action {
+	if len(actions) == 0 {
+		return actions
+	}
+	readable := make([]Action, len(actions))
+	copy(readable, actions)
+	for i := range readable {
+		button := &readable[i].Button
+		for _, color := range []*string{&button.Color, &button.TextColor} {
+			if c, err := ParseColor(*color); err == nil {
+				*color = c.Hex()
+			}
+		}
+		if button.Color == "" || button.TextColor != "" {
+			continue
+		}
+		if text := readableText(button.Color, options.ButtonTextColor); text != options.ButtonTextColor {
+			button.TextColor = text
+		}
+	}
+	return readable
+}
+
+// checkContrast lists the colors below MinContrastRatio set by the theme options or the buttons of an email
+// Only colors given by the user are checked: given are the options before the defaults of the theme are applied.
+func checkContrast(given, options ThemeOptions, actions []Action) ValidationErrors {
+	var errs ValidationErrors
+	check := func(field, text, background string) {
+		fg, err := ParseColor(text)
+		if err != nil {
+			return
+		}
+		bg, err := ParseColor(background)
+		if err != nil {
+			return
+		}
+		if ratio := ContrastRatio(fg, bg); ratio < MinContrastRatio {
+			errs.add(field, "contrast ratio of %s on %s is %.2f:1, below %.1f:1", text, background, ratio, MinContrastRatio)
+		}
+	}
+	if given.PrimaryColor != "" || given.ButtonTextColor != "" {
+		check("themeOptions.buttonTextColor", options.ButtonTextColor, options.PrimaryColor)
+	}
+	for i, action := range actions {
+		button := action.Button
+		if button.Color == "" && button.TextColor == "" {
+			continue
+		}
+		text, background := button.TextColor, button.Color
+		if text == "" {
+			text = options.ButtonTextColor
+		}
+		if background == "" {
+			background = options.PrimaryColor
+		}
+		check(fmt.Sprintf("body.actions[%d].button.textColor", i), text, background)
+	}
+	return errs
+}
+
+// Palette is a set of colors derived from a brand color
+type Palette struct {
+	Primary    Color // Brand color, used for buttons
+	Hover      Color // Primary darkened, e.g. for hovered buttons
+	Border     Color // Light tint of primary, for borders
+	Background Color // Very light tint of primary, for muted backgrounds
+	Text       Color // Black or white, whichever is the most readable on primary
+	Link       Color // Primary, darkened until readable on white
+}
+
+// NewPalette derives a palette from a brand color
+func NewPalette(primary Color) Palette {
+	return Palette{
+		Primary:    primary,
+		Hover:      primary.Shade(0.15),
+		Border:     primary.Tint(0.7),
+		Background: primary.Tint(0.92),
+		Text:       primary.ContrastingText(),
+		Link:       primary.readableOn(White),
+	}
+}
+
+// ThemeOptions returns the options coloring bundled themes with the palette
+// Other options are left to the defaults of the theme.
+func (p Palette) ThemeOptions() ThemeOptions {
+	return ThemeOptions{
+		PrimaryColor:         p.Primary.Hex(),
+		ButtonTextColor:      p.Text.Hex(),
+		ButtonHoverColor:     p.Hover.Hex(),
+		LinkColor:            p.Link.Hex(),
+		BorderColor:          p.Border.Hex(),
+		MutedBackgroundColor: p.Background.Hex(),
+	}
+}
+
+// namedColors are the colors named by CSS
+// https://www.w3.org/TR/css-color-4/#named-colors
+var namedColors = map[string]Color{
+	"aliceblue":            {240, 248, 255},
+	"antiquewhite":         {250, 235, 215},
+	"aqua":                 {0, 255, 255},
+	"aquamarine":           {127, 255, 212},
+	"azure":                {240, 255, 255},
+	"beige":                {245, 245, 220},
+	"bisque":               {255, 228, 196},
+	"black":                {0, 0, 0},
+	"blanchedalmond":       {255, 235, 205},
+	"blue":                 {0, 0, 255},
+	"blueviolet":           {138, 43, 226},
+	"brown":                {165, 42, 42},
+	"burlywood":            {222, 184, 135},
+	"cadetblue":            {95, 158, 160},
+	"chartreuse":           {127, 255, 0},
+	"chocolate":            {210, 105, 30},
+	"coral":                {255, 127, 80},
+	"cornflowerblue":       {100, 149, 237},
+	"cornsilk":             {255, 248, 220},
+	"crimson":              {220, 20, 60},
+	"cyan":                 {0, 255, 255},
+	"darkblue":             {0, 0, 139},
+	"darkcyan":             {0, 139, 139},
+	"darkgoldenrod":        {184, 134, 11},
+	"darkgray":             {169, 169, 169},
+	"darkgreen":            {0, 100, 0},
+	"darkgrey":             {169, 169, 169},
+	"darkkhaki":            {189, 183, 107},
+	"darkmagenta":          {139, 0, 139},
+	"darkolivegreen":       {85, 107, 47},
+	"darkorange":           {255, 140, 0},
+	"darkorchid":           {153, 50, 204},
+	"darkred":              {139, 0, 0},
+	"darksalmon":           {233, 150, 122},
+	"darkseagreen":         {143, 188, 143},
+	"darkslateblue":        {72, 61, 139},
+	"darkslategray":        {47, 79, 79},
+	"darkslategrey":        {47, 79, 79},
+	"darkturquoise":        {0, 206, 209},
+	"darkviolet":           {148, 0, 211},
+	"deeppink":             {255, 20, 147},
+	"deepskyblue":          {0, 191, 255},
+	"dimgray":              {105, 105, 105},
+	"dimgrey":              {105, 105, 105},
+	"dodgerblue":           {30, 144, 255},
+	"firebrick":            {178, 34, 34},
+	"floralwhite":          {255, 250, 240},
+	"forestgreen":          {34, 139, 34},
+	"fuchsia":              {255, 0, 255},
+	"gainsboro":            {220, 220, 220},
+	"ghostwhite":           {248, 248, 255},
+	"gold":                 {255, 215, 0},
+	"goldenrod":            {218, 165, 32},
+	"gray":                 {128, 128, 128},
+	"green":                {0, 128, 0},
+	"greenyellow":          {173, 255, 47},
+	"grey":                 {128, 128, 128},
+	"honeydew":             {240, 255, 240},
+	"hotpink":              {255, 105, 180},
+	"indianred":            {205, 92, 92},
+	"indigo":               {75, 0, 130},
+	"ivory":                {255, 255, 240},
+	"khaki":                {240, 230, 140},
+	"lavender":             {230, 230, 250},
+	"lavenderblush":        {255, 240, 245},
+	"lawngreen":            {124, 252, 0},
+	"lemonchiffon":         {255, 250, 205},
+	"lightblue":            {173, 216, 230},
+	"lightcoral":           {240, 128, 128},
+	"lightcyan":            {224, 255, 255},
+	"lightgoldenrodyellow": {250, 250, 210},
+	"lightgray":            {211, 211, 211},
+	"lightgreen":           {144, 238, 144},
+	"lightgrey":            {211, 211, 211},
+	"lightpink":            {255, 182, 193},
+	"lightsalmon":          {255, 160, 122},
+	"lightseagreen":        {32, 178, 170},
+	"lightskyblue":         {135, 206, 250},
+	"lightslategray":       {119, 136, 153},
+	"lightslategrey":       {119, 136, 153},
+	"lightsteelblue":       {176, 196, 222},
+	"lightyellow":          {255, 255, 224},
+	"lime":                 {0, 255, 0},
+	"limegreen":            {50, 205, 50},
+	"linen":                {250, 240, 230},
+	"magenta":              {255, 0, 255},
+	"maroon":               {128, 0, 0},
+	"mediumaquamarine":     {102, 205, 170},
+	"mediumblue":           {0, 0, 205},
+	"mediumorchid":         {186, 85, 211},
+	"mediumpurple":         {147, 112, 219},
+	"mediumseagreen":       {60, 179, 113},
+	"mediumslateblue":      {123, 104, 238},
+	"mediumspringgreen":    {0, 250, 154},
+	"mediumturquoise":      {72, 209, 204},
+	"mediumvioletred":      {199, 21, 133},
+	"midnightblue":         {25, 25, 112},
+	"mintcream":            {245, 255, 250},
+	"mistyrose":            {255, 228, 225},
+	"moccasin":             {255, 228, 181},
+	"navajowhite":          {255, 222, 173},
+	"navy":                 {0, 0, 128},
+	"oldlace":              {253, 245, 230},
+	"olive":                {128, 128, 0},
+	"olivedrab":            {107, 142, 35},
+	"orange":               {255, 165, 0},
+	"orangered":            {255, 69, 0},
+	"orchid":               {218, 112, 214},
+	"palegoldenrod":        {238, 232, 170},
+	"palegreen":            {152, 251, 152},
+	"paleturquoise":        {175, 238, 238},
+	"palevioletred":        {219, 112, 147},
+	"papayawhip":           {255, 239, 213},
+	"peachpuff":            {255, 218, 185},
+	"peru":                 {205, 133, 63},
+	"pink":                 {255, 192, 203},
+	"plum":                 {221, 160, 221},
+	"powderblue":           {176, 224, 230},
+	"purple":               {128, 0, 128},
+	"rebeccapurple":        {102, 51, 153},
+	"red":                  {255, 0, 0},
+	"rosybrown":            {188, 143, 143},
+	"royalblue":            {65, 105, 225},
+	"saddlebrown":          {139, 69, 19},
+	"salmon":               {250, 128, 114},
+	"sandybrown":           {244, 164, 96},
+	"seagreen":             {46, 139, 87},
+	"seashell":             {255, 245, 238},
+	"sienna":               {160, 82, 45},
+	"silver":               {192, 192, 192},
+	"skyblue":              {135, 206, 235},
+	"slateblue":            {106, 90, 205},
+	"slategray":            {112, 128, 144},
+	"slategrey":            {112, 128, 144},
+	"snow":                 {255, 250, 250},
+	"springgreen":          {0, 255, 127},
+	"steelblue":            {70, 130, 180},
+	"tan":                  {210, 180, 140},
+	"teal":                 {0, 128, 128},
+	"thistle":              {216, 191, 216},
+	"tomato":               {255, 99, 71},
+	"turquoise":            {64, 224, 208},
+	"violet":               {238, 130, 238},
+	"wheat":                {245, 222, 179},
+	"white":                {255, 255, 255},
+	"whitesmoke":           {245, 245, 245},
+	"yellow":               {255, 255, 0},
+	"yellowgreen":          {154, 205, 50},
+}
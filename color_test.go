@@ -0,0 +1,159 @@
+package hermes
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColor(t *testing.T) {
+	for s, expected := range map[string]Color{
+		"#3869D4":           {0x38, 0x69, 0xD4},
+		"#3869d4":           {0x38, 0x69, 0xD4},
+		"#FFF":              White,
+		"#000000":           Black,
+		"rgb(56, 105, 212)": {0x38, 0x69, 0xD4},
+		"rgb(0,0,0)":        Black,
+		"red":               {0xFF, 0x00, 0x00},
+		"RebeccaPurple":     {0x66, 0x33, 0x99},
+	} {
+		c, err := ParseColor(s)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, c, "Color %s", s)
+	}
+	for _, s := range []string{"", "3869D4", "#3869D", "#GGGGGG", "#+12345", "notacolor", "rgb(256, 0, 0)", "rgb(1, 2)", "rgb(1, 2, 3"} {
+		_, err := ParseColor(s)
+		assert.NotNil(t, err, "Color %q should be invalid", s)
+	}
+	assert.Equal(t, "#3869D4", Color{0x38, 0x69, 0xD4}.Hex())
+}
+
+func TestValidColor(t *testing.T) {
+	for _, s := range []string{"#3869D4", "navy", "rgba(56, 105, 212, 0.5)", "hsl(150, 70%, 44%)", "#3869D480", "#FFF8", "transparent", "currentColor"} {
+		assert.True(t, validColor(s), "Color %q should be valid", s)
+	}
+	for _, s := range []string{"", "#3869D", "#GGGGGG", "red; background-image: url(https://tracker.example/p)", "red</style>", "red /* comment */"} {
+		assert.False(t, validColor(s), "Color %q should be invalid", s)
+	}
+
+	for _, button := range []Button{
+		{Text: "Confirm", Link: "https://hermes-example.com/confirm", Color: "rgba(56, 105, 212, 0.5)", TextColor: "transparent"},
+		{Text: "Confirm", Link: "https://hermes-example.com/confirm", Color: "hsl(150, 70%, 44%)", TextColor: "rgba(0, 0, 0, 0.5)"},
+	} {
+		email := Email{Body{Actions: []Action{{Button: button}}}}
+		assert.Nil(t, email.Validate(), "Colors not parsed should not be rejected")
+		for _, theme := range Themes() {
+			h := Hermes{Theme: theme, ContrastPolicy: ContrastEnforce}
+			res, err := h.GenerateHTML(email)
+			assert.Nil(t, err, "Colors not parsed should not be checked for contrast")
+			assert.NotContains(t, res, "ZgotmplZ", "Valid colors of %s should not be filtered", theme.Name())
+			assert.Regexp(t, `class="button" style="[^"]*background-color:`+regexp.QuoteMeta(button.Color)+`;`, res)
+			assert.Regexp(t, `class="button" style="[^"]*;color:`+regexp.QuoteMeta(button.TextColor)+`[;"]`, res)
+			assert.Regexp(t, `<v:roundrect [^>]*style="[^"]*background-color:`+regexp.QuoteMeta(button.Color)+`;"`, res, "Outlook buttons of %s should have the color", theme.Name())
+		}
+	}
+}
+
+func TestColor_Mix(t *testing.T) {
+	c := Color{0x38, 0x69, 0xD4}
+	assert.Equal(t, c, c.Mix(White, 0))
+	assert.Equal(t, White, c.Tint(1))
+	assert.Equal(t, Black, c.Shade(1))
+	assert.Equal(t, Color{0x80, 0x80, 0x80}, White.Mix(Black, 0.5))
+	assert.Equal(t, Color{0x9C, 0xB4, 0xEA}, c.Tint(0.5))
+	assert.Equal(t, Color{0x1C, 0x35, 0x6A}, c.Shade(0.5))
+	assert.Equal(t, White, c.Tint(2), "Weight should be capped to 1")
+}
+
+func TestContrastRatio(t *testing.T) {
+	assert.InDelta(t, 21, ContrastRatio(Black, White), 0.001)
+	assert.InDelta(t, 21, ContrastRatio(White, Black), 0.001, "Contrast ratio should not depend on the order of colors")
+	assert.InDelta(t, 1, ContrastRatio(White, White), 0.001)
+	assert.InDelta(t, 0, Black.Luminance(), 0.001)
+	assert.InDelta(t, 1, White.Luminance(), 0.001)
+	// #767676 is the lightest gray readable on white
+	assert.InDelta(t, 4.54, ContrastRatio(Color{0x76, 0x76, 0x76}, White), 0.01)
+	assert.InDelta(t, 5.07, ContrastRatio(Color{0x38, 0x69, 0xD4}, White), 0.01)
+	assert.InDelta(t, 3.74, ContrastRatio(Color{0x00, 0x94, 0x8D}, White), 0.01)
+}
+
+func TestColor_ContrastingText(t *testing.T) {
+	assert.Equal(t, White, Color{0x38, 0x69, 0xD4}.ContrastingText())
+	assert.Equal(t, White, Black.ContrastingText())
+	assert.Equal(t, Black, White.ContrastingText())
+	assert.Equal(t, Black, Color{0xFF, 0xEB, 0x3B}.ContrastingText(), "Yellow should get black text")
+}
+
+func TestNewPalette(t *testing.T) {
+	for _, hex := range []string{"#3869D4", "#00948D", "#FFEB3B", "#FF5722", "#000000", "#FFFFFF"} {
+		primary, err := ParseColor(hex)
+		assert.Nil(t, err)
+		p := NewPalette(primary)
+		assert.Equal(t, primary, p.Primary)
+		assert.True(t, p.Hover.Luminance() <= primary.Luminance(), "Hover of %s should be darker", hex)
+		assert.True(t, p.Background.Luminance() >= p.Border.Luminance(), "Background of %s should be lighter than the border", hex)
+		assert.True(t, p.Border.Luminance() >= primary.Luminance(), "Border of %s should be lighter", hex)
+		assert.True(t, ContrastRatio(p.Link, White) >= MinContrastRatio, "Link of %s should be readable on white", hex)
+		assert.Equal(t, primary.ContrastingText(), p.Text)
+	}
+
+	primary, _ := ParseColor("#3869D4")
+	assert.Equal(t, primary, NewPalette(primary).Link, "Readable colors should be kept for links")
+	assert.Equal(t, ThemeOptions{
+		PrimaryColor:         "#3869D4",
+		ButtonTextColor:      "#FFFFFF",
+		ButtonHoverColor:     "#3059B4",
+		LinkColor:            "#3869D4",
+		BorderColor:          "#C3D2F2",
+		MutedBackgroundColor: "#EFF3FC",
+	}, NewPalette(primary).ThemeOptions())
+
+	for _, theme := range Themes() {
+		h := Hermes{Theme: theme, ThemeOptions: NewPalette(Color{0xFF, 0xEB, 0x3B}).ThemeOptions(), DisableCSSInlining: true}
+		res, err := h.GenerateHTML(Email{Body{Actions: []Action{{InviteCode: "123456"}}}})
+		assert.Nil(t, err)
+		assert.Contains(t, res, "background-color: #FFEB3B;")
+		assert.Contains(t, res, "color: #000000 !important;")
+		assert.Regexp(t, `\.button:hover {\s*background-color: #D9C832 !important;`, res, "Hovered buttons of %s should be darker", theme.Name())
+		assert.Contains(t, res, "border-top: 1px solid #FFF9C4;", "Borders of %s should be tinted", theme.Name())
+		assert.Contains(t, res, "background-color: #FFFDEF;", "Invite codes of %s should have a muted background", theme.Name())
+	}
+}
+
+func TestHermes_ReadableButtons(t *testing.T) {
+	email := Email{Body{Actions: []Action{
+		{Button: Button{Text: "Default", Link: "https://hermes-example.com/default"}},
+		{Button: Button{Text: "Yellow", Link: "https://hermes-example.com/yellow", Color: "#FFEB3B"}},
+		{Button: Button{Text: "Dark", Link: "https://hermes-example.com/dark", Color: "#2F3133"}},
+		{Button: Button{Text: "Explicit", Link: "https://hermes-example.com/explicit", Color: "#FFEB3B", TextColor: "#FFFFFF"}},
+	}}}
+	h := Hermes{Theme: new(Default)}
+	res, err := h.GenerateHTML(email)
+	assert.Nil(t, err)
+	assert.Regexp(t, `href="https://hermes-example.com/yellow" class="button" style="[^"]*color:#000000`, res, "Text of yellow buttons should be black")
+	assert.Regexp(t, `href="https://hermes-example.com/dark" class="button" style="[^"]*color:#FFFFFF`, res, "Text of the theme should be kept when readable")
+	assert.Regexp(t, `href="https://hermes-example.com/explicit" class="button" style="[^"]*color:#FFFFFF`, res, "Explicit text colors should be kept")
+	assert.Equal(t, "", email.Body.Actions[1].Button.TextColor, "Email should not be modified")
+
+	h.ThemeOptions = ThemeOptions{PrimaryColor: "#FFEB3B"}
+	res, err = h.GenerateHTML(email)
+	assert.Nil(t, err)
+	assert.Regexp(t, `href="https://hermes-example.com/default" class="button" style="[^"]*color:#000000`, res, "Text of buttons should follow the primary color")
+
+	h.ContrastPolicy = ContrastWarn
+	rendered, err := h.Generate(email)
+	assert.Nil(t, err)
+	assert.Equal(t, ValidationErrors{{Field: "body.actions[3].button.textColor", Message: "contrast ratio of #FFFFFF on #FFEB3B is 1.22:1, below 4.5:1"}}, rendered.Warnings)
+
+	h.ContrastPolicy = ContrastEnforce
+	_, err = h.GenerateHTML(email)
+	assert.Equal(t, ValidationErrors{{Field: "body.actions[3].button.textColor", Message: "contrast ratio of #FFFFFF on #FFEB3B is 1.22:1, below 4.5:1"}}, err)
+	h.ThemeOptions = ThemeOptions{PrimaryColor: "#FFEB3B", ButtonTextColor: "#FFFFFF"}
+	_, err = h.GenerateHTML(Email{})
+	assert.EqualError(t, err, "hermes: invalid email: themeOptions.buttonTextColor: contrast ratio of #FFFFFF on #FFEB3B is 1.22:1, below 4.5:1")
+
+	h = Hermes{Theme: new(Flat), ContrastPolicy: ContrastEnforce}
+	_, err = h.GenerateHTML(Email{Body{Actions: []Action{{Button: Button{Text: "Confirm", Link: "https://hermes-example.com/confirm"}}}}})
+	assert.Nil(t, err, "Colors of the theme should not be checked")
+}
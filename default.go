@@ -8,18 +8,36 @@ func (dt *Default) Name() string {
 	return "default"
 }
 
-// HTMLTemplate returns a Golang template that will generate an HTML email.
-func (dt *Default) HTMLTemplate() string {
-	return `
-<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
-<html xmlns="http://www.w3.org/1999/xhtml">
-<head>
-  <meta name="viewport" content="width=device-width, initial-scale=1.0" />
-  <meta http-equiv="Content-Type" content="text/html; charset=UTF-8" />
-  <style type="text/css" rel="stylesheet" media="all">
-    /* Base ------------------------------ */
+// DefaultOptions returns the colors, fonts and sizes of the default theme
+func (dt *Default) DefaultOptions() ThemeOptions {
+	return ThemeOptions{
+		PrimaryColor:         "#3869D4",
+		ButtonTextColor:      "#FFFFFF",
+		SecondaryColor:       "#2F3133",
+		BackgroundColor:      "#F2F4F6",
+		TextColor:            "#74787E",
+		LinkColor:            "#3869D4",
+		BorderColor:          "#EDEFF2",
+		MutedBackgroundColor: "#F2F4F6",
+		FontFamily:           "Arial, 'Helvetica Neue', Helvetica, sans-serif",
+		MonospaceFontFamily:  "Consolas, monaco, monospace",
+		ContentWidth:         570,
+		BorderRadius:         3,
+		ButtonHeight:         45,
+
+		DarkBackgroundColor:        "#1F2023",
+		DarkContentBackgroundColor: "#2B2D31",
+		DarkTextColor:              "#C3C6CB",
+		DarkSecondaryColor:         "#F2F4F6",
+		DarkLinkColor:              "#8AB4F8",
+	}
+}
+
+// Stylesheet returns the CSS of the default theme, included in its HTML template with {{ template "stylesheet" . }}
+func (dt *Default) Stylesheet() string {
+	return `    /* Base ------------------------------ */
     *:not(br):not(tr):not(html) {
-      font-family: Arial, 'Helvetica Neue', Helvetica, sans-serif;
+      font-family: {{ .Hermes.ThemeOptions.FontFamily | css }};
       -webkit-box-sizing: border-box;
       box-sizing: border-box;
     }
@@ -28,19 +46,19 @@ func (dt *Default) HTMLTemplate() string {
       height: 100%;
       margin: 0;
       line-height: 1.4;
-      background-color: #F2F4F6;
-      color: #74787E;
+      background-color: {{ .Hermes.ThemeOptions.BackgroundColor | css }};
+      color: {{ .Hermes.ThemeOptions.TextColor | css }};
       -webkit-text-size-adjust: none;
     }
     a {
-      color: #3869D4;
+      color: {{ .Hermes.ThemeOptions.LinkColor | css }};
     }
     /* Layout ------------------------------ */
     .email-wrapper {
       width: 100%;
       margin: 0;
       padding: 0;
-      background-color: #F2F4F6;
+      background-color: {{ .Hermes.ThemeOptions.BackgroundColor | css }};
     }
     .email-content {
       width: 100%;
@@ -59,7 +77,7 @@ func (dt *Default) HTMLTemplate() string {
     .email-masthead_name {
       font-size: 16px;
       font-weight: bold;
-      color: #2F3133;
+      color: {{ .Hermes.ThemeOptions.SecondaryColor | css }};
       text-decoration: none;
       text-shadow: 0 1px 0 white;
     }
@@ -71,17 +89,17 @@ func (dt *Default) HTMLTemplate() string {
       width: 100%;
       margin: 0;
       padding: 0;
-      border-top: 1px solid #EDEFF2;
-      border-bottom: 1px solid #EDEFF2;
+      border-top: 1px solid {{ .Hermes.ThemeOptions.BorderColor | css }};
+      border-bottom: 1px solid {{ .Hermes.ThemeOptions.BorderColor | css }};
       background-color: #FFF;
     }
     .email-body_inner {
-      width: 570px;
+      width: {{ .Hermes.ThemeOptions.ContentWidth }}px;
       margin: 0 auto;
       padding: 0;
     }
     .email-footer {
-      width: 570px;
+      width: {{ .Hermes.ThemeOptions.ContentWidth }}px;
       margin: 0 auto;
       padding: 0;
       text-align: center;
@@ -116,7 +134,7 @@ func (dt *Default) HTMLTemplate() string {
     .body-sub {
       margin-top: 25px;
       padding-top: 25px;
-      border-top: 1px solid #EDEFF2;
+      border-top: 1px solid {{ .Hermes.ThemeOptions.BorderColor | css }};
       table-layout: fixed;
     }
     .body-sub a {
@@ -131,19 +149,19 @@ func (dt *Default) HTMLTemplate() string {
     /* Type ------------------------------ */
     h1 {
       margin-top: 0;
-      color: #2F3133;
+      color: {{ .Hermes.ThemeOptions.SecondaryColor | css }};
       font-size: 19px;
       font-weight: bold;
     }
     h2 {
       margin-top: 0;
-      color: #2F3133;
+      color: {{ .Hermes.ThemeOptions.SecondaryColor | css }};
       font-size: 16px;
       font-weight: bold;
     }
     h3 {
       margin-top: 0;
-      color: #2F3133;
+      color: {{ .Hermes.ThemeOptions.SecondaryColor | css }};
       font-size: 14px;
       font-weight: bold;
     }
@@ -171,7 +189,7 @@ func (dt *Default) HTMLTemplate() string {
     }
     p {
       margin-top: 0;
-      color: #74787E;
+      color: {{ .Hermes.ThemeOptions.TextColor | css }};
       font-size: 16px;
       line-height: 1.5em;
     }
@@ -187,7 +205,7 @@ func (dt *Default) HTMLTemplate() string {
     th {
       padding: 0px 5px;
       padding-bottom: 8px;
-      border-bottom: 1px solid #EDEFF2;
+      border-bottom: 1px solid {{ .Hermes.ThemeOptions.BorderColor | css }};
     }
     th p {
       margin: 0;
@@ -196,7 +214,7 @@ func (dt *Default) HTMLTemplate() string {
     }
     td {
       padding: 10px 5px;
-      color: #74787E;
+      color: {{ .Hermes.ThemeOptions.TextColor | css }};
       font-size: 15px;
       line-height: 18px;
     }
@@ -218,7 +236,7 @@ func (dt *Default) HTMLTemplate() string {
       text-align: left;
       padding: 0px 5px;
       padding-bottom: 8px;
-      border-bottom: 1px solid #EDEFF2;
+      border-bottom: 1px solid {{ .Hermes.ThemeOptions.BorderColor | css }};
     }
     .data-table th p {
       margin: 0;
@@ -227,7 +245,7 @@ func (dt *Default) HTMLTemplate() string {
     }
     .data-table td {
       padding: 10px 5px;
-      color: #74787E;
+      color: {{ .Hermes.ThemeOptions.TextColor | css }};
       font-size: 15px;
       line-height: 18px;
     }
@@ -238,22 +256,22 @@ func (dt *Default) HTMLTemplate() string {
       padding-right: 36px;
       padding-bottom: 16px;
       padding-left: 36px;
-      border-radius: 3px;
-      font-family: Consolas, monaco, monospace;
+      border-radius: {{ .Hermes.ThemeOptions.BorderRadius }}px;
+      font-family: {{ .Hermes.ThemeOptions.MonospaceFontFamily | css }};
       font-size: 28px;
       text-align: center;
       letter-spacing: 8px;
       color: #555;
-      background-color: #eee;
+      background-color: {{ .Hermes.ThemeOptions.MutedBackgroundColor | css }};
     }
     /* Buttons ------------------------------ */
     .button {
       display: inline-block;
-      background-color: #3869D4;
-      border-radius: 3px;
-      color: #ffffff !important;
+      background-color: {{ .Hermes.ThemeOptions.PrimaryColor | css }};
+      border-radius: {{ .Hermes.ThemeOptions.BorderRadius }}px;
+      color: {{ .Hermes.ThemeOptions.ButtonTextColor | css }} !important;
       font-size: 15px;
-      line-height: 45px;
+      line-height: {{ .Hermes.ThemeOptions.ButtonHeight }}px;
       text-align: center;
       text-decoration: none;
       -webkit-text-size-adjust: none;
@@ -271,7 +289,102 @@ func (dt *Default) HTMLTemplate() string {
         width: 100% !important;
       }
     }
+`
+}
+
+// HTMLTemplate returns a Golang template that will generate an HTML email.
+func (dt *Default) HTMLTemplate() string {
+	return `
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+  <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+  <meta http-equiv="Content-Type" content="text/html; charset=UTF-8" />
+{{- if not .Hermes.ThemeOptions.DisableDarkMode }}
+  <meta name="color-scheme" content="light dark" />
+  <meta name="supported-color-schemes" content="light dark" />
+{{- end }}
+  <style type="text/css" rel="stylesheet" media="all">
+{{ template "stylesheet" . }}  </style>
+  {{ block "dark-mode" . }}{{ if not .Hermes.ThemeOptions.DisableDarkMode }}<style type="text/css" data-premailer="ignore">
+    {{- /* Dark mode styles cannot be inlined: they are kept as is by premailer */}}
+    :root {
+      color-scheme: light dark;
+      supported-color-schemes: light dark;
+    }
+    @media (prefers-color-scheme: dark) {
+      body,
+      .email-wrapper {
+        background-color: {{ .Hermes.ThemeOptions.DarkBackgroundColor | css }} !important;
+      }
+      .email-body {
+        border-color: {{ .Hermes.ThemeOptions.DarkBackgroundColor | css }} !important;
+        background-color: {{ .Hermes.ThemeOptions.DarkContentBackgroundColor | css }} !important;
+      }
+      body,
+      p,
+      td,
+      dd,
+      .email-footer p {
+        color: {{ .Hermes.ThemeOptions.DarkTextColor | css }} !important;
+      }
+      h1,
+      h2,
+      h3,
+      dt,
+      .email-masthead_name {
+        color: {{ .Hermes.ThemeOptions.DarkSecondaryColor | css }} !important;
+        text-shadow: none !important;
+      }
+      a:not(.button) {
+        color: {{ .Hermes.ThemeOptions.DarkLinkColor | css }} !important;
+      }
+      .light-img {
+        display: none !important;
+      }
+      .dark-img {
+        display: block !important;
+        overflow: visible !important;
+        max-height: none !important;
+      }
+    }
+    {{- /* Outlook.com marks the elements it inverts with data-ogsc (text) and data-ogsb (background) attributes */}}
+    [data-ogsb] body,
+    [data-ogsb] .email-wrapper {
+      background-color: {{ .Hermes.ThemeOptions.DarkBackgroundColor | css }} !important;
+    }
+    [data-ogsb] .email-body {
+      background-color: {{ .Hermes.ThemeOptions.DarkContentBackgroundColor | css }} !important;
+    }
+    [data-ogsc] p,
+    [data-ogsc] td,
+    [data-ogsc] dd {
+      color: {{ .Hermes.ThemeOptions.DarkTextColor | css }} !important;
+    }
+    [data-ogsc] h1,
+    [data-ogsc] h2,
+    [data-ogsc] h3,
+    [data-ogsc] dt,
+    [data-ogsc] .email-masthead_name {
+      color: {{ .Hermes.ThemeOptions.DarkSecondaryColor | css }} !important;
+    }
+    [data-ogsc] .light-img {
+      display: none !important;
+    }
+    [data-ogsc] .dark-img {
+      display: block !important;
+      overflow: visible !important;
+      max-height: none !important;
+    }
+  </style>{{ end }}{{ end }}
+{{- with .Hermes.ThemeOptions.ButtonHoverColor }}
+  <style type="text/css" data-premailer="ignore">
+    {{- /* Hover styles cannot be inlined: they are kept as is by premailer */}}
+    .button:hover {
+      background-color: {{ . | css }} !important;
+    }
   </style>
+{{- end }}
 </head>
 <body dir="{{.Hermes.TextDirection}}">
   <table class="email-wrapper" width="100%" cellpadding="0" cellspacing="0">
@@ -279,50 +392,51 @@ func (dt *Default) HTMLTemplate() string {
       <td class="content">
         <table class="email-content" width="100%" cellpadding="0" cellspacing="0">
           <!-- Logo -->
-          <tr>
+          {{ block "masthead" . }}<tr>
             <td class="email-masthead">
-              <a class="email-masthead_name" href="{{.Hermes.Product.Link}}" target="_blank">
+              <a class="email-masthead_name" href="{{.Hermes.Product.Link | url }}" target="_blank">
                 {{ if .Hermes.Product.Logo }}
-                  <img src="{{.Hermes.Product.Logo | url }}" class="email-logo" />
+                  <img src="{{.Hermes.Product.Logo | url }}" class="email-logo{{ if .Hermes.Product.DarkLogo }} light-img{{ end }}" />{{ with .Hermes.Product.DarkLogo }}
+                  {{ safe "<!--[if !mso]><!-->" }}<div class="dark-img" style="display:none;overflow:hidden;max-height:0;mso-hide:all"><img src="{{ . | url }}" class="email-logo" /></div>{{ safe "<!--<![endif]-->" }}{{ end }}
                 {{ else }}
                   {{ .Hermes.Product.Name }}
                 {{ end }}
                 </a>
             </td>
-          </tr>
+          </tr>{{ end }}
 
           <!-- Email Body -->
           <tr>
             <td class="email-body" width="100%">
-              <table class="email-body_inner" align="center" width="570" cellpadding="0" cellspacing="0">
+              <table class="email-body_inner" align="center" width="{{ .Hermes.ThemeOptions.ContentWidth }}" cellpadding="0" cellspacing="0">
                 <!-- Body content -->
                 <tr>
                   <td class="content-cell">
-                    <h1>{{if .Email.Body.Title }}{{ .Email.Body.Title }}{{ else }}{{ .Email.Body.Greeting }} {{ .Email.Body.Name }},{{ end }}</h1>
-                    {{ with .Email.Body.Intros }}
+                    {{ block "greeting" . }}<h1>{{if .Email.Body.Title }}{{ .Email.Body.Title }}{{ else }}{{ .Email.Body.Greeting }} {{ .Email.Body.Name }},{{ end }}</h1>{{ end }}
+                    {{ block "intros" . }}{{ with .Email.Body.Intros }}
                         {{ if gt (len .) 0 }}
                           {{ range $line := . }}
-                            <p>{{ $line }}</p>
+                            <p>{{ $.InlineHTML $line }}</p>
                           {{ end }}
                         {{ end }}
-                    {{ end }}
+                    {{ end }}{{ end }}
                     {{ if (ne .Email.Body.FreeMarkdown "") }}
-                      {{ .Email.Body.FreeMarkdown.ToHTML }}
+                      {{ .FreeMarkdownHTML }}
                     {{ else }}
 
-                      {{ with .Email.Body.Dictionary }} 
+                      {{ block "dictionary" . }}{{ with .Email.Body.Dictionary }} 
                         {{ if gt (len .) 0 }}
                           <dl class="body-dictionary">
                             {{ range $entry := . }}
                               <dt>{{ $entry.Key }}:</dt>
-                              <dd>{{ $entry.Value }}</dd>
+                              <dd>{{ $.InlineHTML $entry.Value }}</dd>
                             {{ end }}
                           </dl>
                         {{ end }}
-                      {{ end }}
+                      {{ end }}{{ end }}
 
                       <!-- Table -->
-                      {{ with .Email.Body.Table }}
+                      {{ block "table" . }}{{ with .Email.Body.Table }}
                         {{ $data := .Data }}
                         {{ $columns := .Columns }}
                         {{ if gt (len $data) 0 }}
@@ -360,7 +474,7 @@ func (dt *Default) HTMLTemplate() string {
                                             {{ end }}
                                           {{ end }}
                                         >
-                                          {{ $cell.Value }}
+                                          {{ $.InlineHTML $cell.Value }}
                                         </td>
                                       {{ end }}
                                     </tr>
@@ -370,58 +484,22 @@ func (dt *Default) HTMLTemplate() string {
                             </tr>
                           </table>
                         {{ end }}
-                      {{ end }}
+                      {{ end }}{{ end }}
 
                       <!-- Action -->
-                      {{ with .Email.Body.Actions }}
+                      {{ block "actions" . }}{{ with .Email.Body.Actions }}
                         {{ if gt (len .) 0 }}
                           {{ range $action := . }}
                             <p>{{ $action.Instructions }}</p>
-                            {{ $length := len $action.Button.Text }}
-                            {{ $width := add (mul $length 9) 20 }}
-                            {{if (lt $width 200)}}{{$width = 200}}{{else if (gt $width 570)}}{{$width = 570}}{{else}}{{end}}
-                              {{safe "<!--[if mso]>" }}
-                              {{ if $action.Button.Text }}
-                                <div style="margin: 30px auto;v-text-anchor:middle;text-align:center">
-                                  <v:roundrect xmlns:v="urn:schemas-microsoft-com:vml" 
-                                    xmlns:w="urn:schemas-microsoft-com:office:word" 
-                                    href="{{ $action.Button.Link }}" 
-                                    style="height:45px;v-text-anchor:middle;width:{{$width}}px;background-color:{{ if $action.Button.Color }}{{ $action.Button.Color }}{{ else }}#3869D4{{ end }};"
-                                    arcsize="10%" 
-                                    {{ if $action.Button.Color }}strokecolor="{{ $action.Button.Color }}" fillcolor="{{ $action.Button.Color }}"{{ else }}strokecolor="#3869D4" fillcolor="#3869D4"{{ end }}
-                                    >
-                                    <w:anchorlock/>
-                                    <center style="color: {{ if $action.Button.TextColor }}{{ $action.Button.TextColor }}{{else}}#FFFFFF{{ end }};font-size: 15px;text-align: center;font-family:sans-serif;font-weight:bold;">
-                                      {{ $action.Button.Text }}
-                                    </center>
-                                  </v:roundrect>
-                                </div>
-                              {{ end }}
-                              {{ if $action.InviteCode }}
-                                <div style="margin-top:30px;margin-bottom:30px">
-                                  <table class="body-action" align="center" width="100%" cellpadding="0" cellspacing="0">
-                                    <tr>
-                                      <td align="center">
-                                        <table align="center" cellpadding="0" cellspacing="0" style="padding:0;text-align:center">
-                                          <tr>
-                                            <td style="display:inline-block;border-radius:3px;font-family:Consolas, monaco, monospace;font-size:28px;text-align:center;letter-spacing:8px;color:#555;background-color:#eee;padding:20px">
-                                              {{ $action.InviteCode }}
-                                            </td>
-                                          </tr>
-                                        </table>
-                                      </td>
-                                    </tr>
-                                  </table>
-                                </div>
-                              {{ end }}   
-                              {{safe "<![endif]-->" }}
+                            {{ $width := add (textWidth $action.Button.Text 15) 20 | max 200 | min (sub $.Hermes.ThemeOptions.ContentWidth 70) }}
+                              {{ template "mso-action" (dict "Hermes" $.Hermes "Action" $action "Width" $width "ArcSize" "10%") }}
                               {{safe "<!--[if !mso]><!-- -->"}}
                               <table class="body-action" align="center" width="100%" cellpadding="0" cellspacing="0">
                                 <tr>
                                   <td align="center">
                                     <div>
                                       {{ if $action.Button.Text }}
-                                        <a href="{{ $action.Button.Link }}" class="button" style="{{ with $action.Button.Color }}background-color: {{ . }};{{ end }} {{ with $action.Button.TextColor }}color: {{ . }};{{ end }} width: {{$width}}px;" target="_blank">
+                                        <a href="{{ $action.Button.Link | url }}" class="button" style="{{ with $action.Button.Color }}background-color: {{ . | css }};{{ end }} {{ with $action.Button.TextColor }}color: {{ . | css }};{{ end }} width: {{$width}}px;" target="_blank">
                                           {{ $action.Button.Text }}
                                         </a>
                                       {{end}}
@@ -435,24 +513,24 @@ func (dt *Default) HTMLTemplate() string {
                               {{safe "<![endif]-->" }}
                           {{ end }}
                         {{ end }}
-                      {{ end }}
+                      {{ end }}{{ end }}
 
                     {{ end }}
-                    {{ with .Email.Body.Outros }} 
+                    {{ block "outros" . }}{{ with .Email.Body.Outros }} 
                         {{ if gt (len .) 0 }}
                           {{ range $line := . }}
-                            <p>{{ $line }}</p>
+                            <p>{{ $.InlineHTML $line }}</p>
                           {{ end }}
                         {{ end }}
-                      {{ end }}
+                      {{ end }}{{ end }}
 
-                    <p>
+                    {{ block "signature" . }}<p>
                       {{.Email.Body.Signature}},
                       <br />
                       {{.Hermes.Product.Name}}
-                    </p>
+                    </p>{{ end }}
 
-                    {{ if (eq .Email.Body.FreeMarkdown "") }}
+                    {{ block "trouble-links" . }}{{ if (eq .Email.Body.FreeMarkdown "") }}
                       {{ with .Email.Body.Actions }} 
                         <table class="body-sub">
                           <tbody>
@@ -461,7 +539,7 @@ func (dt *Default) HTMLTemplate() string {
                                 <tr>
                                   <td>
                                     <p class="sub">{{$.Hermes.Product.TroubleText | replace "{ACTION}" $action.Button.Text}}</p>
-                                    <p class="sub"><a href="{{ $action.Button.Link }}">{{ $action.Button.Link }}</a></p>
+                                    <p class="sub"><a href="{{ $action.Button.Link | url }}">{{ $action.Button.Link }}</a></p>
                                   </td>
                                 </tr>
                                 {{ end }}
@@ -469,7 +547,7 @@ func (dt *Default) HTMLTemplate() string {
                           </tbody>
                         </table>
                       {{ end }}
-                    {{ end }}
+                    {{ end }}{{ end }}
                   </td>
                 </tr>
               </table>
@@ -477,7 +555,7 @@ func (dt *Default) HTMLTemplate() string {
           </tr>
           <tr>
             <td>
-              <table class="email-footer" align="center" width="570" cellpadding="0" cellspacing="0">
+              {{ block "footer" . }}<table class="email-footer" align="center" width="{{ .Hermes.ThemeOptions.ContentWidth }}" cellpadding="0" cellspacing="0">
                 <tr>
                   <td class="content-cell">
                     <p class="sub center">
@@ -485,7 +563,7 @@ func (dt *Default) HTMLTemplate() string {
                     </p>
                   </td>
                 </tr>
-              </table>
+              </table>{{ end }}
             </td>
           </tr>
         </table>
@@ -499,23 +577,23 @@ func (dt *Default) HTMLTemplate() string {
 
 // PlainTextTemplate returns a Golang template that will generate an plain text email.
 func (dt *Default) PlainTextTemplate() string {
-	return `<h2>{{if .Email.Body.Title }}{{ .Email.Body.Title }}{{ else }}{{ .Email.Body.Greeting }} {{ .Email.Body.Name }},{{ end }}</h2>
-{{ with .Email.Body.Intros }}
+	return `{{ block "greeting" . }}<h2>{{if .Email.Body.Title }}{{ .Email.Body.Title }}{{ else }}{{ .Email.Body.Greeting }} {{ .Email.Body.Name }},{{ end }}</h2>{{ end }}
+{{ block "intros" . }}{{ with .Email.Body.Intros }}
   {{ range $line := . }}
-    <p>{{ $line }}</p>
+    <p>{{ $.InlineHTML $line }}</p>
   {{ end }}
-{{ end }}
+{{ end }}{{ end }}
 {{ if (ne .Email.Body.FreeMarkdown "") }}
-  {{ .Email.Body.FreeMarkdown.ToHTML }}
+  {{ .FreeMarkdownHTML }}
 {{ else }}
-  {{ with .Email.Body.Dictionary }}
+  {{ block "dictionary" . }}{{ with .Email.Body.Dictionary }}
     <ul>
     {{ range $entry := . }}
-      <li>{{ $entry.Key }}: {{ $entry.Value }}</li>
+      <li>{{ $entry.Key }}: {{ $.InlineHTML $entry.Value }}</li>
     {{ end }}
     </ul>
-  {{ end }}
-  {{ with .Email.Body.Table }}
+  {{ end }}{{ end }}
+  {{ block "table" . }}{{ with .Email.Body.Table }}
     {{ $data := .Data }}
     {{ $columns := .Columns }}
     {{ if gt (len $data) 0 }}
@@ -530,15 +608,15 @@ func (dt *Default) PlainTextTemplate() string {
           <tr>
             {{ range $cell := $row }}
               <td>
-                {{ $cell.Value }}
+                {{ $.InlineHTML $cell.Value }}
               </td>
             {{ end }}
           </tr>
         {{ end }}
       </table>
     {{ end }}
-  {{ end }}
-  {{ with .Email.Body.Actions }} 
+  {{ end }}{{ end }}
+  {{ block "actions" . }}{{ with .Email.Body.Actions }} 
     {{ range $action := . }}
       <p>
         {{ $action.Instructions }} 
@@ -550,15 +628,15 @@ func (dt *Default) PlainTextTemplate() string {
         {{ end }}
       </p> 
     {{ end }}
-  {{ end }}
+  {{ end }}{{ end }}
 {{ end }}
-{{ with .Email.Body.Outros }} 
+{{ block "outros" . }}{{ with .Email.Body.Outros }} 
   {{ range $line := . }}
-    <p>{{ $line }}<p>
+    <p>{{ $.InlineHTML $line }}<p>
   {{ end }}
-{{ end }}
-<p>{{.Email.Body.Signature}},<br>{{.Hermes.Product.Name}} - {{.Hermes.Product.Link}}</p>
+{{ end }}{{ end }}
+{{ block "signature" . }}<p>{{.Email.Body.Signature}},<br>{{.Hermes.Product.Name}} - {{.Hermes.Product.Link}}</p>{{ end }}
 
-<p>{{.Hermes.Product.Copyright}}</p>
+{{ block "footer" . }}<p>{{.Hermes.Product.Copyright}}</p>{{ end }}
 `
 }
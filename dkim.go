@@ -0,0 +1,372 @@
+package hermes
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DKIMCanonicalization is a canonicalization algorithm of DKIM signatures (RFC 6376 section 3.4)
+type DKIMCanonicalization string
+
+// DKIMSimple is the simple canonicalization algorithm, tolerating almost no modification
+const DKIMSimple DKIMCanonicalization = "simple"
+
+// DKIMRelaxed is the relaxed canonicalization algorithm, tolerating whitespace modifications (default)
+const DKIMRelaxed DKIMCanonicalization = "relaxed"
+
+// DefaultDKIMHeaders is the default list of header fields covered by DKIM signatures
+var DefaultDKIMHeaders = []string{"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID", "MIME-Version", "Content-Type"}
+
+// DKIMOptions are the options to sign messages with DKIM (RFC 6376)
+type DKIMOptions struct {
+	Domain                 string               // Signing domain (d= tag), e.g. example.com
+	Selector               string               // Selector of the public key in DNS (s= tag), e.g. hermes for hermes._domainkey.example.com
+	Signer                 crypto.Signer        // Private key, either *rsa.PrivateKey (rsa-sha256) or ed25519.PrivateKey (ed25519-sha256)
+	HeaderCanonicalization DKIMCanonicalization // Canonicalization of header fields (default to relaxed)
+	BodyCanonicalization   DKIMCanonicalization // Canonicalization of the body (default to relaxed)
+	Headers                []string             // Header fields to sign when present in the message (default to DefaultDKIMHeaders)
+}
+
+// DKIMKeyLookup returns the public key of a DKIM selector, usually published in DNS under <selector>._domainkey.<domain>
+type DKIMKeyLookup func(domain, selector string) (crypto.PublicKey, error)
+
+// SignDKIM signs a serialized message and returns it with a DKIM-Signature header field prepended
+func SignDKIM(message []byte, options DKIMOptions) ([]byte, error) {
+	if options.Domain == "" || options.Selector == "" {
+		return nil, errors.New("hermes: DKIM domain and selector are required")
+	}
+	algorithm, err := dkimAlgorithm(options.Signer)
+	if err != nil {
+		return nil, err
+	}
+	headerCanon, bodyCanon, err := dkimCanonicalizations(options.HeaderCanonicalization, options.BodyCanonicalization)
+	if err != nil {
+		return nil, err
+	}
+	headers, body := splitMessage(message)
+	fields := parseHeaderFields(headers)
+
+	names := options.Headers
+	if len(names) == 0 {
+		names = DefaultDKIMHeaders
+	}
+	var signedNames []string
+	for _, name := range names {
+		if _, ok := lastHeaderField(fields, name, nil); ok {
+			signedNames = append(signedNames, strings.ToLower(name))
+		}
+	}
+	if !containsFold(signedNames, "from") {
+		return nil, errors.New("hermes: DKIM signature must cover the From header field")
+	}
+
+	bodyHash := sha256.Sum256(canonicalizeBody(body, bodyCanon))
+	tags := []string{
+		"v=1",
+		"a=" + algorithm,
+		"c=" + string(headerCanon) + "/" + string(bodyCanon),
+		"d=" + options.Domain,
+		"s=" + options.Selector,
+		"t=" + strconv.FormatInt(time.Now().Unix(), 10),
+		"h=" + strings.Join(signedNames, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]),
+		"b=",
+	}
+	signature := foldHeader("DKIM-Signature: " + strings.Join(tags, "; "))
+
+	digest := dkimHeadersHash(fields, signedNames, signature, headerCanon)
+	var opts crypto.SignerOpts = crypto.SHA256
+	if algorithm == "ed25519-sha256" {
+		// Ed25519 signs the SHA-256 hash as a message (RFC 8463)
+		opts = crypto.Hash(0)
+	}
+	b, err := options.Signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var signed bytes.Buffer
+	signed.WriteString(signature)
+	lastLine := signature
+	if i := strings.LastIndex(signature, "\r\n"); i >= 0 {
+		lastLine = signature[i+2:]
+	}
+	writeFoldedBase64(&signed, base64.StdEncoding.EncodeToString(b), len(lastLine))
+	signed.WriteString("\r\n")
+	signed.Write(message)
+	return signed.Bytes(), nil
+}
+
+// VerifyDKIM verifies the first DKIM-Signature header field of a serialized message
+func VerifyDKIM(message []byte, lookup DKIMKeyLookup) error {
+	headers, body := splitMessage(message)
+	fields := parseHeaderFields(headers)
+	signature, ok := firstHeaderField(fields, "DKIM-Signature")
+	if !ok {
+		return errors.New("hermes: message has no DKIM signature")
+	}
+	tags := parseDKIMTags(signature[len("DKIM-Signature:"):])
+	for _, tag := range []string{"v", "a", "d", "s", "h", "bh", "b"} {
+		if _, ok := tags[tag]; !ok {
+			return fmt.Errorf("hermes: DKIM signature has no %s= tag", tag)
+		}
+	}
+	if tags["v"] != "1" {
+		return fmt.Errorf("hermes: unsupported DKIM version %q", tags["v"])
+	}
+	// Canonicalizations default to simple when not specified (RFC 6376 section 3.5)
+	headerCanon, bodyCanon := DKIMSimple, DKIMSimple
+	if c := tags["c"]; c != "" {
+		canon := strings.SplitN(c, "/", 2)
+		headerCanon = DKIMCanonicalization(canon[0])
+		if len(canon) == 2 {
+			bodyCanon = DKIMCanonicalization(canon[1])
+		}
+	}
+	_, _, err := dkimCanonicalizations(headerCanon, bodyCanon)
+	if err != nil {
+		return err
+	}
+
+	bodyHash := sha256.Sum256(canonicalizeBody(body, bodyCanon))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != stripWhitespace(tags["bh"]) {
+		return errors.New("hermes: DKIM body hash does not match")
+	}
+
+	key, err := lookup(tags["d"], tags["s"])
+	if err != nil {
+		return err
+	}
+	b, err := base64.StdEncoding.DecodeString(stripWhitespace(tags["b"]))
+	if err != nil {
+		return fmt.Errorf("hermes: invalid DKIM signature: %v", err)
+	}
+	var names []string
+	for _, name := range strings.Split(tags["h"], ":") {
+		names = append(names, strings.TrimSpace(name))
+	}
+	if !containsFold(names, "from") {
+		return errors.New("hermes: DKIM signature does not cover the From header field")
+	}
+	digest := dkimHeadersHash(fields, names, dkimSignatureValueRegexp.ReplaceAllString(signature, "${1}"), headerCanon)
+
+	switch tags["a"] {
+	case "rsa-sha256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("hermes: DKIM key is not a RSA key")
+		}
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, b)
+		if err != nil {
+			return fmt.Errorf("hermes: invalid DKIM signature: %v", err)
+		}
+	case "ed25519-sha256":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("hermes: DKIM key is not an Ed25519 key")
+		}
+		if !ed25519.Verify(pub, digest, b) {
+			return errors.New("hermes: invalid DKIM signature")
+		}
+	default:
+		return fmt.Errorf("hermes: unsupported DKIM algorithm %q", tags["a"])
+	}
+	return nil
+}
+
+// dkimSignatureValueRegexp matches the value of the b= tag, which is removed when computing the signature
+var dkimSignatureValueRegexp = regexp.MustCompile(`((?:^|;)[ \t\r\n]*b[ \t\r\n]*=)[^;]*`)
+
+func dkimAlgorithm(signer crypto.Signer) (string, error) {
+	switch signer.(type) {
+	case *rsa.PrivateKey:
+		return "rsa-sha256", nil
+	case ed25519.PrivateKey:
+		return "ed25519-sha256", nil
+	case nil:
+		return "", errors.New("hermes: DKIM signer is required")
+	default:
+		return "", fmt.Errorf("hermes: unsupported DKIM key type %T", signer)
+	}
+}
+
+func dkimCanonicalizations(header, body DKIMCanonicalization) (DKIMCanonicalization, DKIMCanonicalization, error) {
+	if header == "" {
+		header = DKIMRelaxed
+	}
+	if body == "" {
+		body = DKIMRelaxed
+	}
+	for _, c := range []DKIMCanonicalization{header, body} {
+		if c != DKIMSimple && c != DKIMRelaxed {
+			return "", "", fmt.Errorf("hermes: unsupported DKIM canonicalization %q", c)
+		}
+	}
+	return header, body, nil
+}
+
+// dkimHeadersHash computes the hash of the signed header fields, followed by the DKIM-Signature header field without its signature
+func dkimHeadersHash(fields []string, names []string, signature string, canon DKIMCanonicalization) []byte {
+	h := sha256.New()
+	used := make(map[int]bool)
+	for _, name := range names {
+		// Several instances of a header field are signed from the bottom up (RFC 6376 section 5.4.2)
+		field, ok := lastHeaderField(fields, name, used)
+		if !ok {
+			continue
+		}
+		h.Write([]byte(canonicalizeHeader(field, canon) + "\r\n"))
+	}
+	h.Write([]byte(canonicalizeHeader(signature, canon)))
+	return h.Sum(nil)
+}
+
+// splitMessage splits a serialized message into its header and body
+func splitMessage(message []byte) (string, []byte) {
+	i := bytes.Index(message, []byte("\r\n\r\n"))
+	if i < 0 {
+		return string(message), nil
+	}
+	return string(message[:i+2]), message[i+4:]
+}
+
+// parseHeaderFields splits a header into its fields, keeping their folding
+func parseHeaderFields(headers string) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(headers, "\r\n") {
+		if line == "" {
+			continue
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+func headerFieldName(field string) string {
+	return strings.TrimSpace(strings.SplitN(field, ":", 2)[0])
+}
+
+func firstHeaderField(fields []string, name string) (string, bool) {
+	for _, field := range fields {
+		if strings.EqualFold(headerFieldName(field), name) {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// lastHeaderField returns the last instance of a header field not already used, marking it as used
+func lastHeaderField(fields []string, name string, used map[int]bool) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if !used[i] && strings.EqualFold(headerFieldName(fields[i]), name) {
+			if used != nil {
+				used[i] = true
+			}
+			return fields[i], true
+		}
+	}
+	return "", false
+}
+
+var whitespacesRegexp = regexp.MustCompile(`[ \t]+`)
+
+// canonicalizeHeader canonicalizes a header field, without its trailing CRLF (RFC 6376 section 3.4.1 and 3.4.2)
+func canonicalizeHeader(field string, canon DKIMCanonicalization) string {
+	if canon == DKIMSimple {
+		return field
+	}
+	kv := strings.SplitN(field, ":", 2)
+	value := ""
+	if len(kv) == 2 {
+		value = kv[1]
+	}
+	value = strings.Replace(value, "\r\n", "", -1)
+	value = strings.TrimSpace(whitespacesRegexp.ReplaceAllString(value, " "))
+	return strings.ToLower(strings.TrimSpace(kv[0])) + ":" + value
+}
+
+// canonicalizeBody canonicalizes a message body (RFC 6376 section 3.4.3 and 3.4.4)
+func canonicalizeBody(body []byte, canon DKIMCanonicalization) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	if canon == DKIMRelaxed {
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(whitespacesRegexp.ReplaceAllString(line, " "), " ")
+		}
+	}
+	// Empty lines at the end of the body are ignored
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		if canon == DKIMSimple {
+			return []byte("\r\n")
+		}
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// parseDKIMTags parses a tag list (RFC 6376 section 3.2)
+func parseDKIMTags(value string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, ";") {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		tags[stripWhitespace(kv[0])] = strings.TrimSpace(strings.Replace(kv[1], "\r\n", "", -1))
+	}
+	return tags
+}
+
+func stripWhitespace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFoldedBase64 writes a base64 value folded on several lines, the first one already having the given length
+func writeFoldedBase64(b *bytes.Buffer, value string, lineLength int) {
+	for len(value) > 0 {
+		n := maxHeaderLineLength - lineLength
+		if n <= 0 {
+			b.WriteString("\r\n ")
+			lineLength = 1
+			continue
+		}
+		if n > len(value) {
+			n = len(value)
+		}
+		b.WriteString(value[:n])
+		lineLength += n
+		value = value[n:]
+	}
+}
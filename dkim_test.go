@@ -0,0 +1,155 @@
+package hermes
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func generateDKIMKeys(t *testing.T) map[string]crypto.Signer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{
+		"rsa-sha256":     rsaKey,
+		"ed25519-sha256": ed25519Key,
+	}
+}
+
+func dkimLookup(signer crypto.Signer) DKIMKeyLookup {
+	return func(domain, selector string) (crypto.PublicKey, error) {
+		if domain != "example.com" || selector != "hermes" {
+			return nil, errors.New("no key found")
+		}
+		return signer.Public(), nil
+	}
+}
+
+func writeTestMessage(t *testing.T, m *Message) []byte {
+	var b bytes.Buffer
+	_, err := m.WriteTo(&b)
+	assert.Nil(t, err)
+	return b.Bytes()
+}
+
+func TestSignDKIM_RoundTrip(t *testing.T) {
+	for algorithm, signer := range generateDKIMKeys(t) {
+		for _, headerCanon := range []DKIMCanonicalization{DKIMSimple, DKIMRelaxed} {
+			for _, bodyCanon := range []DKIMCanonicalization{DKIMSimple, DKIMRelaxed} {
+				m := getTestMessage(t)
+				m.DKIM = &DKIMOptions{
+					Domain:                 "example.com",
+					Selector:               "hermes",
+					Signer:                 signer,
+					HeaderCanonicalization: headerCanon,
+					BodyCanonicalization:   bodyCanon,
+				}
+				signed := writeTestMessage(t, m)
+
+				assert.True(t, bytes.HasPrefix(signed, []byte("DKIM-Signature: ")), "Signature should be the first header field")
+				assert.Contains(t, string(signed), "a="+algorithm)
+				assert.Contains(t, string(signed), "c="+string(headerCanon)+"/"+string(bodyCanon))
+				assert.Contains(t, string(signed), "h=from:reply-to:subject:date:to:cc:message-id:mime-version:content-type;")
+				for _, line := range strings.Split(string(signed), "\r\n") {
+					assert.True(t, len(line) <= 78, "Line should not exceed 78 characters: %q", line)
+				}
+				err := VerifyDKIM(signed, dkimLookup(signer))
+				assert.Nil(t, err, "Signature should be valid with %s and %s/%s canonicalization", algorithm, headerCanon, bodyCanon)
+			}
+		}
+	}
+}
+
+func TestSignDKIM_CustomHeaders(t *testing.T) {
+	signer := generateDKIMKeys(t)["ed25519-sha256"]
+	m := getTestMessage(t)
+	m.DKIM = &DKIMOptions{
+		Domain:   "example.com",
+		Selector: "hermes",
+		Signer:   signer,
+		Headers:  []string{"From", "Subject", "X-Not-Present"},
+	}
+	signed := writeTestMessage(t, m)
+	assert.Contains(t, string(signed), "h=from:subject;", "Only present header fields should be signed")
+
+	// Modifying a header field that is not signed keeps the signature valid
+	modified := bytes.Replace(signed, []byte("Cc: \"Bran Stark\""), []byte("Cc: \"Brandon Stark\""), 1)
+	assert.NotEqual(t, signed, modified)
+	assert.Nil(t, VerifyDKIM(modified, dkimLookup(signer)))
+
+	m.DKIM.Headers = []string{"Subject"}
+	_, err := m.WriteTo(new(bytes.Buffer))
+	assert.NotNil(t, err, "Signature should always cover the From header field")
+}
+
+func TestVerifyDKIM_Tampered(t *testing.T) {
+	for algorithm, signer := range generateDKIMKeys(t) {
+		m := getTestMessage(t)
+		m.DKIM = &DKIMOptions{Domain: "example.com", Selector: "hermes", Signer: signer, HeaderCanonicalization: DKIMSimple, BodyCanonicalization: DKIMSimple}
+		signed := writeTestMessage(t, m)
+
+		tamperedBody := bytes.Replace(signed, []byte("Welcome to Hermes"), []byte("Welcome to Hades!"), 1)
+		assert.NotNil(t, VerifyDKIM(tamperedBody, dkimLookup(signer)), "Modified body should be detected with %s", algorithm)
+
+		tamperedHeader := bytes.Replace(signed, []byte("Message-ID: <welcome@example.com>"), []byte("Message-ID: <phishing@example.com>"), 1)
+		assert.NotNil(t, VerifyDKIM(tamperedHeader, dkimLookup(signer)), "Modified header should be detected with %s", algorithm)
+
+		other := generateDKIMKeys(t)[algorithm]
+		assert.NotNil(t, VerifyDKIM(signed, dkimLookup(other)), "Signature should not be valid with another key")
+
+		assert.NotNil(t, VerifyDKIM(writeTestMessage(t, getTestMessage(t)), dkimLookup(signer)), "Unsigned message should not be valid")
+	}
+}
+
+func TestVerifyDKIM_RelaxedToleratesWhitespaces(t *testing.T) {
+	signer := generateDKIMKeys(t)["rsa-sha256"]
+	m := getTestMessage(t)
+	m.DKIM = &DKIMOptions{Domain: "example.com", Selector: "hermes", Signer: signer}
+	signed := writeTestMessage(t, m)
+
+	// Relays may refold headers and change whitespaces
+	modified := bytes.Replace(signed, []byte("Message-ID: <welcome@example.com>"), []byte("message-id:   <welcome@example.com>  "), 1)
+	modified = append(modified, []byte("\r\n\r\n")...)
+	assert.Nil(t, VerifyDKIM(modified, dkimLookup(signer)))
+}
+
+func TestSignDKIM_InvalidOptions(t *testing.T) {
+	signer := generateDKIMKeys(t)["ed25519-sha256"]
+	message := writeTestMessage(t, getTestMessage(t))
+
+	for _, options := range []DKIMOptions{
+		{Selector: "hermes", Signer: signer},
+		{Domain: "example.com", Signer: signer},
+		{Domain: "example.com", Selector: "hermes"},
+		{Domain: "example.com", Selector: "hermes", Signer: signer, BodyCanonicalization: "nowsp"},
+	} {
+		_, err := SignDKIM(message, options)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestCanonicalizeBody(t *testing.T) {
+	body := []byte("Hi  Jon \t Snow  \r\n\r\nWinter is coming\r\n\r\n\r\n")
+	assert.Equal(t, "Hi  Jon \t Snow  \r\n\r\nWinter is coming\r\n", string(canonicalizeBody(body, DKIMSimple)))
+	assert.Equal(t, "Hi Jon Snow\r\n\r\nWinter is coming\r\n", string(canonicalizeBody(body, DKIMRelaxed)))
+	assert.Equal(t, "\r\n", string(canonicalizeBody(nil, DKIMSimple)))
+	assert.Equal(t, "", string(canonicalizeBody(nil, DKIMRelaxed)))
+}
+
+func TestCanonicalizeHeader(t *testing.T) {
+	field := "Subject :  Winter \r\n\tis   coming  "
+	assert.Equal(t, field, canonicalizeHeader(field, DKIMSimple))
+	assert.Equal(t, "subject:Winter is coming", canonicalizeHeader(field, DKIMRelaxed))
+}
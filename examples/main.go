@@ -1,9 +1,8 @@
 package main
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"github.com/go-gomail/gomail"
 	"github.com/matcornic/hermes/v2"
 	"golang.org/x/crypto/ssh/terminal"
 	"io/ioutil"
@@ -59,36 +58,41 @@ func main() {
 			bytePassword, _ := terminal.ReadPassword(0)
 			password = string(bytePassword)
 		}
-		smtpConfig := smtpAuthentication{
-			Server:         os.Getenv("HERMES_SMTP_SERVER"),
-			Port:           port,
-			SenderEmail:    os.Getenv("HERMES_SENDER_EMAIL"),
-			SenderIdentity: os.Getenv("HERMES_SENDER_IDENTITY"),
-			SMTPPassword:   password,
-			SMTPUser:       SMTPUser,
+		mailer := &hermes.SMTPMailer{
+			Host:     os.Getenv("HERMES_SMTP_SERVER"),
+			Port:     port,
+			Username: SMTPUser,
+			Password: password,
 		}
-		options := sendOptions{
-			To: os.Getenv("HERMES_TO"),
+		if port == 465 {
+			mailer.Security = hermes.SMTPImplicitTLS
 		}
+		from := mail.Address{
+			Name:    os.Getenv("HERMES_SENDER_IDENTITY"),
+			Address: os.Getenv("HERMES_SENDER_EMAIL"),
+		}
+		var messages []*hermes.Message
 		for _, theme := range themes {
 			h.Theme = theme
 			for _, e := range examples {
-				options.Subject = "Hermes | " + h.Theme.Name() + " | " + e.Name()
-				fmt.Printf("Sending email '%s'...\n", options.Subject)
-				htmlBytes, err := ioutil.ReadFile(fmt.Sprintf("%v/%v.%v.html", h.Theme.Name(), h.Theme.Name(), e.Name()))
-				if err != nil {
-					panic(err)
-				}
-				txtBytes, err := ioutil.ReadFile(fmt.Sprintf("%v/%v.%v.txt", h.Theme.Name(), h.Theme.Name(), e.Name()))
-				if err != nil {
-					panic(err)
-				}
-				err = send(smtpConfig, options, string(htmlBytes), string(txtBytes))
+				subject := "Hermes | " + h.Theme.Name() + " | " + e.Name()
+				fmt.Printf("Sending email '%s'...\n", subject)
+				rendered, err := h.Generate(e.Email())
 				if err != nil {
 					panic(err)
 				}
+				messages = append(messages, &hermes.Message{
+					From:     from.String(),
+					To:       []string{os.Getenv("HERMES_TO")},
+					Subject:  subject,
+					Rendered: rendered,
+				})
 			}
 		}
+		err := mailer.Send(context.Background(), messages...)
+		if err != nil {
+			panic(err)
+		}
 	}
 }
 
@@ -117,62 +121,3 @@ func generateEmails(h hermes.Hermes, email hermes.Email, example string) {
 		panic(err)
 	}
 }
-
-type smtpAuthentication struct {
-	Server         string
-	Port           int
-	SenderEmail    string
-	SenderIdentity string
-	SMTPUser       string
-	SMTPPassword   string
-}
-
-// sendOptions are options for sending an email
-type sendOptions struct {
-	To      string
-	Subject string
-}
-
-// send sends the email
-func send(smtpConfig smtpAuthentication, options sendOptions, htmlBody string, txtBody string) error {
-
-	if smtpConfig.Server == "" {
-		return errors.New("SMTP server config is empty")
-	}
-	if smtpConfig.Port == 0 {
-		return errors.New("SMTP port config is empty")
-	}
-
-	if smtpConfig.SMTPUser == "" {
-		return errors.New("SMTP user is empty")
-	}
-
-	if smtpConfig.SenderIdentity == "" {
-		return errors.New("SMTP sender identity is empty")
-	}
-
-	if smtpConfig.SenderEmail == "" {
-		return errors.New("SMTP sender email is empty")
-	}
-
-	if options.To == "" {
-		return errors.New("no receiver emails configured")
-	}
-
-	from := mail.Address{
-		Name:    smtpConfig.SenderIdentity,
-		Address: smtpConfig.SenderEmail,
-	}
-
-	m := gomail.NewMessage()
-	m.SetHeader("From", from.String())
-	m.SetHeader("To", options.To)
-	m.SetHeader("Subject", options.Subject)
-
-	m.SetBody("text/plain", txtBody)
-	m.AddAlternative("text/html", htmlBody)
-
-	d := gomail.NewDialer(smtpConfig.Server, smtpConfig.Port, smtpConfig.SMTPUser, smtpConfig.SMTPPassword)
-
-	return d.DialAndSend(m)
-}
@@ -0,0 +1,90 @@
+package hermes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// File is a file embedded in a message, either as an inline image or as an attachment
+// Inline images are referenced in the HTML email with a `cid:` URL instead of a public URL,
+// which many corporate email clients block by default.
+type File struct {
+	Name        string // File name, e.g. logo.png. Also used as Content-ID for inline images
+	ContentType string // MIME type of the content (default detected from Name extension, then from Content)
+	Content     []byte // Content of the file
+}
+
+// ReadFile reads a local file so that it can be embedded in a message
+func ReadFile(path string) (*File, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		Name:    filepath.Base(path),
+		Content: content,
+	}, nil
+}
+
+// ContentID returns the identifier of the file when embedded as an inline image
+func (f *File) ContentID() string {
+	return f.Name
+}
+
+// URL returns the `cid:` URL referencing the file when embedded as an inline image
+// e.g. in FreeMarkdown: ![Chart](cid:chart.png)
+func (f *File) URL() string {
+	return "cid:" + f.ContentID()
+}
+
+// MediaType returns the MIME type of the file, detecting it when not explicitly set
+// Invalid content types are replaced with application/octet-stream, so that they can't add header fields.
+func (f *File) MediaType() string {
+	if f.ContentType != "" {
+		mediaType, err := f.parseContentType()
+		if err != nil {
+			return "application/octet-stream"
+		}
+		return mediaType
+	}
+	if t := mime.TypeByExtension(filepath.Ext(f.Name)); t != "" {
+		return t
+	}
+	return http.DetectContentType(f.Content)
+}
+
+// parseContentType returns the content type of the file, formatted as a header value
+func (f *File) parseContentType() (string, error) {
+	mediaType, params, err := mime.ParseMediaType(f.ContentType)
+	if err != nil {
+		return "", fmt.Errorf("hermes: file %q has an invalid content type %q: %v", f.Name, f.ContentType, err)
+	}
+	formatted := mime.FormatMediaType(mediaType, params)
+	if formatted == "" {
+		return "", fmt.Errorf("hermes: file %q has an invalid content type %q", f.Name, f.ContentType)
+	}
+	return formatted, nil
+}
+
+// validateImage checks that a file can be embedded as an inline image
+func validateImage(f *File) error {
+	if f.Name == "" {
+		return fmt.Errorf("hermes: inline image has no name")
+	}
+	if strings.ContainsAny(f.Name, "<>\"\r\n ") {
+		return fmt.Errorf("hermes: inline image name %q cannot be used as Content-ID", f.Name)
+	}
+	if f.ContentType != "" {
+		if _, err := f.parseContentType(); err != nil {
+			return err
+		}
+	}
+	if !strings.HasPrefix(f.MediaType(), "image/") {
+		return fmt.Errorf("hermes: inline image %q is not an image but %s", f.Name, f.MediaType())
+	}
+	return nil
+}
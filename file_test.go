@@ -0,0 +1,69 @@
+package hermes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFile(t *testing.T) {
+	f, err := ReadFile("examples/gopher.png")
+	assert.Nil(t, err)
+	assert.Equal(t, "gopher.png", f.Name)
+	assert.NotEmpty(t, f.Content)
+	assert.Equal(t, "image/png", f.MediaType())
+	assert.Equal(t, "cid:gopher.png", f.URL())
+
+	_, err = ReadFile("examples/not-existing.png")
+	assert.NotNil(t, err)
+}
+
+func TestFile_MediaType(t *testing.T) {
+	assert.Equal(t, "image/gif", (&File{Name: "image", ContentType: "image/gif"}).MediaType(), "Explicit content type should be used")
+	assert.Equal(t, "image/jpeg", (&File{Name: "photo.jpg"}).MediaType(), "Content type should be detected from extension")
+	assert.Equal(t, "image/png", (&File{Name: "logo", Content: []byte("\x89PNG\x0D\x0A\x1A\x0A")}).MediaType(), "Content type should be detected from content")
+	assert.Equal(t, "text/plain; charset=UTF-8", (&File{Name: "notes", ContentType: "Text/Plain;Charset=UTF-8"}).MediaType(), "Explicit content type should be formatted")
+	assert.Equal(t, "application/octet-stream", (&File{Name: "logo.png", ContentType: "image/png\r\nX-Injected: true"}).MediaType(), "Invalid content type should not be used")
+
+	err := validateImage(&File{Name: "logo.png", ContentType: "image/png\r\nX-Injected: true"})
+	assert.NotNil(t, err, "Inline image with an invalid content type should be rejected")
+}
+
+func TestRenderer_GenerateWithInlineImages(t *testing.T) {
+	logo, err := ReadFile("examples/gopher.png")
+	assert.Nil(t, err)
+	chart := &File{Name: "chart.png", Content: logo.Content}
+
+	for _, theme := range testedThemes {
+		h, email := (&SimpleExample{theme}).getExample()
+		h.Product.LogoFile = logo
+		email.Body.Images = []*File{chart}
+		email.Body.FreeMarkdown = "![Chart](cid:chart.png)"
+		r, err := NewRenderer(h)
+		assert.Nil(t, err)
+
+		rendered, err := r.Generate(email)
+		assert.Nil(t, err)
+		assert.Contains(t, rendered.HTML, `src="cid:gopher.png"`, "Logo should be referenced with its Content-ID")
+		assert.NotContains(t, rendered.HTML, h.Product.Logo, "Logo URL should be replaced by the inline logo")
+		assert.Contains(t, rendered.HTML, `src="cid:chart.png"`, "Body images should be referenced with their Content-ID")
+		assert.Equal(t, []*File{logo, chart}, rendered.Images)
+	}
+}
+
+func TestRenderer_GenerateWithInvalidInlineImages(t *testing.T) {
+	r, err := NewRenderer(Hermes{})
+	assert.Nil(t, err)
+
+	for _, images := range [][]*File{
+		{{Name: "notes.txt", Content: []byte("Not an image")}},
+		{{Content: []byte("\x89PNG\x0D\x0A\x1A\x0A")}},
+		{{Name: "invalid name.png"}},
+		{{Name: "logo.png"}, {Name: "logo.png"}},
+	} {
+		_, err := r.Generate(Email{Body: Body{Images: images}})
+		assert.NotNil(t, err)
+		_, err = r.GenerateHTML(Email{Body: Body{Images: images}})
+		assert.NotNil(t, err)
+	}
+}
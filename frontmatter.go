@@ -0,0 +1,150 @@
+package hermes
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// frontMatter is the YAML front matter of an email written in markdown, read by ReadMarkdownRequest
+// Field names are the ones of RenderRequest documents, e.g. `inviteCode` or `troubleText`.
+type frontMatter struct {
+	Name       string              `yaml:"name"`
+	Title      string              `yaml:"title"`
+	Intros     []string            `yaml:"intros"`
+	Dictionary []frontMatterEntry  `yaml:"dictionary"`
+	Actions    []frontMatterAction `yaml:"actions"`
+	Outros     []string            `yaml:"outros"`
+	Signature  string              `yaml:"signature"`
+	Product    *frontMatterProduct `yaml:"product"`
+}
+
+type frontMatterEntry struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+type frontMatterAction struct {
+	Instructions string `yaml:"instructions"`
+	Button       struct {
+		Color     string `yaml:"color"`
+		TextColor string `yaml:"textColor"`
+		Text      string `yaml:"text"`
+		Link      string `yaml:"link"`
+	} `yaml:"button"`
+	InviteCode string `yaml:"inviteCode"`
+}
+
+type frontMatterProduct struct {
+	Name        string `yaml:"name"`
+	Link        string `yaml:"link"`
+	Logo        string `yaml:"logo"`
+	DarkLogo    string `yaml:"darkLogo"`
+	Copyright   string `yaml:"copyright"`
+	TroubleText string `yaml:"troubleText"`
+}
+
+// frontMatterDelimiter opens the front matter, which is closed by the same line or by "..."
+const frontMatterDelimiter = "---"
+
+// yamlLine matches the line numbers of YAML errors
+var yamlLine = regexp.MustCompile(`\bline (\d+)`)
+
+// ReadMarkdownRequest reads an email written in markdown, whose YAML front matter declares the other parts of the email, e.g.:
+//
+//	---
+//	name: Jon Snow
+//	intros:
+//	  - Welcome to Hermes!
+//	actions:
+//	  - instructions: "To get started with Hermes, please click here:"
+//	    button:
+//	      text: Confirm your account
+//	      link: https://hermes-example.com/confirm
+//	product:
+//	  name: Hermes
+//	---
+//	**Winter** is coming
+//
+// The front matter declares name, title, intros, dictionary, actions, outros, signature and product, overriding non empty fields
+// of the configured one. The markdown following it, if any, becomes the free markdown of the email.
+// Errors in the front matter report their line in the file.
+func ReadMarkdownRequest(r io.Reader) (RenderRequest, error) {
+	var req RenderRequest
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return req, err
+	}
+	matter, markdown, err := splitFrontMatter(string(content))
+	if err != nil {
+		return req, err
+	}
+	var fm frontMatter
+	err = yaml.UnmarshalStrict([]byte(matter), &fm)
+	if err != nil {
+		// The front matter starts on the second line of the file
+		message := yamlLine.ReplaceAllStringFunc(strings.TrimPrefix(err.Error(), "yaml: "), func(s string) string {
+			line, _ := strconv.Atoi(strings.TrimPrefix(s, "line "))
+			return fmt.Sprintf("line %d", line+1)
+		})
+		return req, fmt.Errorf("hermes: invalid front matter: %s", message)
+	}
+
+	body := &req.Email.Body
+	body.Name = fm.Name
+	body.Title = fm.Title
+	body.Intros = fm.Intros
+	for _, entry := range fm.Dictionary {
+		body.Dictionary = append(body.Dictionary, Entry{Key: entry.Key, Value: entry.Value})
+	}
+	for _, action := range fm.Actions {
+		body.Actions = append(body.Actions, Action{
+			Instructions: action.Instructions,
+			Button:       Button(action.Button),
+			InviteCode:   action.InviteCode,
+		})
+	}
+	body.Outros = fm.Outros
+	body.Signature = fm.Signature
+	body.FreeMarkdown = Markdown(markdown)
+	if fm.Product != nil {
+		req.Product = &Product{
+			Name:        fm.Product.Name,
+			Link:        fm.Product.Link,
+			Logo:        fm.Product.Logo,
+			DarkLogo:    fm.Product.DarkLogo,
+			Copyright:   fm.Product.Copyright,
+			TroubleText: fm.Product.TroubleText,
+		}
+	}
+	return req, nil
+}
+
+// splitFrontMatter returns the front matter of content, if any, and the markdown following it
+func splitFrontMatter(content string) (matter string, markdown string, err error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	lines := strings.SplitAfter(content, "\n")
+	if strings.TrimSpace(lines[0]) != frontMatterDelimiter {
+		return "", trimBlankLines(content), nil
+	}
+	for i := 1; i < len(lines); i++ {
+		if delimiter := strings.TrimSpace(lines[i]); delimiter == frontMatterDelimiter || delimiter == "..." {
+			return strings.Join(lines[1:i], ""), trimBlankLines(strings.Join(lines[i+1:], "")), nil
+		}
+	}
+	return "", "", fmt.Errorf("hermes: invalid front matter: line 1: %s is not closed", frontMatterDelimiter)
+}
+
+// trimBlankLines removes the blank lines around markdown, keeping the indentation of its first line
+func trimBlankLines(markdown string) string {
+	lines := strings.SplitAfter(strings.TrimRight(markdown, " \t\r\n"), "\n")
+	for len(lines) > 1 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	return strings.Join(lines, "")
+}
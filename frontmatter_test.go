@@ -0,0 +1,79 @@
+package hermes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMarkdownRequest(t *testing.T) {
+	req, err := ReadMarkdownRequest(strings.NewReader(`---
+name: Jon Snow
+title: Welcome to Hermes
+intros:
+  - Welcome to Hermes!
+dictionary:
+  - key: Firstname
+    value: Jon
+actions:
+  - instructions: "To get started with Hermes, please click here:"
+    button:
+      textColor: "#FFFFFF"
+      text: Confirm your account
+      link: https://hermes-example.com/confirm
+  - inviteCode: 123456
+outros:
+  - Need help? Just reply to this email.
+signature: Thanks
+product:
+  name: Hermes
+  troubleText: Copy the link
+---
+
+# Maintenance
+
+    hermes render
+
+**Winter** is coming
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, Body{
+		Name:       "Jon Snow",
+		Title:      "Welcome to Hermes",
+		Intros:     []string{"Welcome to Hermes!"},
+		Dictionary: []Entry{{Key: "Firstname", Value: "Jon"}},
+		Actions: []Action{
+			{Instructions: "To get started with Hermes, please click here:", Button: Button{TextColor: "#FFFFFF", Text: "Confirm your account", Link: "https://hermes-example.com/confirm"}},
+			{InviteCode: "123456"},
+		},
+		Outros:       []string{"Need help? Just reply to this email."},
+		Signature:    "Thanks",
+		FreeMarkdown: "# Maintenance\n\n    hermes render\n\n**Winter** is coming",
+	}, req.Email.Body)
+	assert.Equal(t, &Product{Name: "Hermes", TroubleText: "Copy the link"}, req.Product)
+
+	req, err = ReadMarkdownRequest(strings.NewReader("---\r\nname: Jon\r\n...\r\n**Winter**\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Jon", req.Email.Body.Name)
+	assert.Equal(t, Markdown("**Winter**"), req.Email.Body.FreeMarkdown)
+	assert.Nil(t, req.Product)
+
+	req, err = ReadMarkdownRequest(strings.NewReader("\n    **Winter** is coming\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, Email{Body{FreeMarkdown: "    **Winter** is coming"}}, req.Email, "Files without front matter should be markdown only")
+}
+
+func TestReadMarkdownRequestInvalid(t *testing.T) {
+	for document, expected := range map[string]string{
+		"---\nname: Jon\n":                           "hermes: invalid front matter: line 1: --- is not closed",
+		"---\nname: Jon\nintros: a: b\n---\n":        "hermes: invalid front matter: line 3: mapping values are not allowed in this context",
+		"---\nname: Jon\nunknown: true\n---\n":       "hermes: invalid front matter: unmarshal errors:\n  line 3: field unknown not found in type hermes.frontMatter",
+		"---\nname: Jon\n\nintros: Welcome\n---\nHi": "hermes: invalid front matter: unmarshal errors:\n  line 4: cannot unmarshal !!str `Welcome` into []string",
+	} {
+		_, err := ReadMarkdownRequest(strings.NewReader(document))
+		if assert.NotNil(t, err, document) {
+			assert.Equal(t, expected, err.Error(), document)
+		}
+	}
+}
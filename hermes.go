@@ -1,14 +1,18 @@
 package hermes
 
 import (
-	"bytes"
+	"context"
+	"fmt"
 	"html/template"
+	"io"
+	"strings"
 
-	"github.com/Masterminds/sprig"
+	"github.com/PuerkitoBio/goquery"
 	"github.com/imdario/mergo"
 	"github.com/jaytaylor/html2text"
 	"github.com/russross/blackfriday/v2"
 	"github.com/vanng822/go-premailer/premailer"
+	"golang.org/x/net/html"
 )
 
 // Hermes is an instance of the hermes email generator
@@ -17,6 +21,13 @@ type Hermes struct {
 	TextDirection      TextDirection
 	Product            Product
 	DisableCSSInlining bool
+	CustomCSS          string                  // CSS appended to the stylesheet of the theme before inlining, e.g. to override colors and fonts
+	ThemeOptions       ThemeOptions            // Colors, fonts and sizes of the theme (default to the ones of the theme)
+	ContrastPolicy     ContrastPolicy          // How button colors below MinContrastRatio are handled (default to ContrastIgnore), warnings being only returned by Generate
+	URLPolicy          URLPolicy               // URLs allowed in links and images (default to DefaultURLSchemes on any host)
+	HTMLPolicy         *HTMLPolicy             // HTML elements and attributes allowed in markdown content (default to DefaultHTMLPolicy())
+	MarkdownExtensions *blackfriday.Extensions // Syntax extensions of markdown content, e.g. blackfriday.Tables, blackfriday.NoExtensions disabling all of them (default to blackfriday.CommonExtensions)
+	Sandbox            *Sandbox                // Restrictions of theme templates coming from outside the application binary (default to the sandbox the theme was loaded in, if any)
 }
 
 // Theme is an interface to implement when creating a new theme
@@ -33,6 +44,10 @@ var templateFuncs = template.FuncMap{
 	"url": func(s string) template.URL {
 		return template.URL(s)
 	},
+	"css": func(s string) template.CSS {
+		return template.CSS(s)
+	},
+	"textWidth": TextWidth,
 }
 
 // TDLeftToRight is the text direction from left to right (default)
@@ -44,11 +59,14 @@ const TDRightToLeft TextDirection = "rtl"
 // Product represents your company product (brand)
 // Appears in header & footer of e-mails
 type Product struct {
-	Name        string
-	Link        string // e.g. https://matcornic.github.io
-	Logo        string // e.g. https://matcornic.github.io/img/logo.png
-	Copyright   string // Copyright © 2019 Hermes. All rights reserved.
-	TroubleText string // TroubleText is the sentence at the end of the email for users having trouble with the button (default to `If you’re having trouble with the button '{ACTION}', copy and paste the URL below into your web browser.`)
+	Name         string
+	Link         string // e.g. https://matcornic.github.io
+	Logo         string // e.g. https://matcornic.github.io/img/logo.png
+	LogoFile     *File  // Logo embedded in the email as an inline image, replacing Logo when set
+	DarkLogo     string // Logo displayed instead of Logo by email clients in dark mode, e.g. https://matcornic.github.io/img/logo-dark.png
+	DarkLogoFile *File  // Dark mode logo embedded in the email as an inline image, replacing DarkLogo when set
+	Copyright    string // Copyright © 2019 Hermes. All rights reserved.
+	TroubleText  string // TroubleText is the sentence at the end of the email for users having trouble with the button (default to `If you’re having trouble with the button '{ACTION}', copy and paste the URL below into your web browser.`)
 }
 
 // Email is the email containing a body
@@ -72,11 +90,17 @@ type Body struct {
 	Signature    string   // Signature for the contacted person (default to 'Yours truly')
 	Title        string   // Title replaces the greeting+name when set
 	FreeMarkdown Markdown // Free markdown content that replaces all content other than header and footer
+	Images       []*File  // Images embedded in the email, referenced in content with their `cid:` URL
+
+	InlineMarkdown bool // Intros, outros, dictionary values and table cells are inline markdown, e.g. **bold**, [links](https://...) and `code`
 }
 
-// ToHTML converts Markdown to HTML
+// ToHTML converts Markdown to HTML styled for emails like the default theme,
+// and sanitized with DefaultHTMLPolicy and the default URLPolicy
 func (c Markdown) ToHTML() template.HTML {
-	return template.HTML(blackfriday.Run([]byte(string(c))))
+	// Disallowed URLs are replaced, and strings never fail to be read or written
+	res, _ := renderMarkdown(string(c), Hermes{}, nil, "")
+	return res
 }
 
 // Entry is a simple entry of a map
@@ -119,6 +143,24 @@ type Button struct {
 type Template struct {
 	Hermes Hermes
 	Email  Email
+
+	warnings     ValidationErrors         // Issues found in the email, reported without failing its generation
+	freeMarkdown template.HTML            // Free markdown content of the email, converted to HTML and sanitized
+	inline       map[string]template.HTML // Texts of the email converted from inline markdown to HTML, when enabled
+}
+
+// FreeMarkdownHTML returns the free markdown content of the email converted to HTML, sanitized with Hermes.HTMLPolicy
+func (t Template) FreeMarkdownHTML() template.HTML {
+	return t.freeMarkdown
+}
+
+// InlineHTML returns a text of the email, i.e. an intro, an outro, a dictionary value or a table cell, as HTML:
+// converted from inline markdown when Body.InlineMarkdown is set, escaped otherwise
+func (t Template) InlineHTML(text string) template.HTML {
+	if converted, ok := t.inline[text]; ok {
+		return converted
+	}
+	return template.HTML(template.HTMLEscapeString(text))
 }
 
 func setDefaultEmailValues(e *Email) error {
@@ -141,7 +183,6 @@ func setDefaultEmailValues(e *Email) error {
 func setDefaultHermesValues(h *Hermes) error {
 	defaultTextDirection := TDLeftToRight
 	defaultHermes := Hermes{
-		Theme:         new(Default),
 		TextDirection: defaultTextDirection,
 		Product: Product{
 			Name:        "Hermes",
@@ -149,6 +190,13 @@ func setDefaultHermesValues(h *Hermes) error {
 			TroubleText: "If you’re having trouble with the button '{ACTION}', copy and paste the URL below into your web browser.",
 		},
 	}
+	// Themes are not merged: their fields are unrelated to the ones of the default theme
+	if h.Theme == nil {
+		h.Theme = new(Default)
+	}
+	if h.Sandbox == nil {
+		h.Sandbox = themeSandbox(h.Theme)
+	}
 	// Merge the given hermes engine configuration with default one
 	// Default one overrides all zero values
 	err := mergo.Merge(h, defaultHermes)
@@ -164,61 +212,304 @@ func setDefaultHermesValues(h *Hermes) error {
 // GenerateHTML generates the email body from data to an HTML Reader
 // This is for modern email clients
 func (h *Hermes) GenerateHTML(email Email) (string, error) {
-	err := setDefaultHermesValues(h)
+	var b strings.Builder
+	err := h.GenerateHTMLTo(&b, email)
 	if err != nil {
 		return "", err
 	}
-	return h.generateTemplate(email, h.Theme.HTMLTemplate())
+	return b.String(), nil
+}
+
+// GenerateHTMLTo generates the email body from data and writes the HTML to w
+// This is for modern email clients
+func (h *Hermes) GenerateHTMLTo(w io.Writer, email Email) error {
+	err := setDefaultHermesValues(h)
+	if err != nil {
+		return err
+	}
+	t, err := parseThemeTemplate(h.Theme, true, h.Sandbox)
+	if err != nil {
+		return err
+	}
+	data, err := templateData(*h, email)
+	if err != nil {
+		return err
+	}
+	return executeHTML(context.Background(), w, t, data)
 }
 
 // GeneratePlainText generates the email body from data
 // This is for old email clients
 func (h *Hermes) GeneratePlainText(email Email) (string, error) {
-	err := setDefaultHermesValues(h)
+	var b strings.Builder
+	err := h.GeneratePlainTextTo(&b, email)
 	if err != nil {
 		return "", err
 	}
-	template, err := h.generateTemplate(email, h.Theme.PlainTextTemplate())
+	return b.String(), nil
+}
+
+// GeneratePlainTextTo generates the email body from data and writes the plain text to w
+// This is for old email clients
+func (h *Hermes) GeneratePlainTextTo(w io.Writer, email Email) error {
+	err := setDefaultHermesValues(h)
 	if err != nil {
-		return "", err
+		return err
+	}
+	t, err := parseThemeTemplate(h.Theme, false, h.Sandbox)
+	if err != nil {
+		return err
 	}
-	return html2text.FromString(template, html2text.Options{PrettyTables: true})
+	data, err := templateData(*h, email)
+	if err != nil {
+		return err
+	}
+	return executePlainText(context.Background(), w, t, data)
 }
 
-func (h *Hermes) generateTemplate(email Email, tplt string) (string, error) {
+// Generate generates both the HTML and the plain text versions of the email
+// Default values are applied once and shared by both versions
+func (h *Hermes) Generate(email Email) (Rendered, error) {
+	return h.GenerateContext(context.Background(), email)
+}
 
-	err := setDefaultEmailValues(&email)
+// GenerateContext generates both the HTML and the plain text versions of the email, until ctx is done
+func (h *Hermes) GenerateContext(ctx context.Context, email Email) (Rendered, error) {
+	err := setDefaultHermesValues(h)
 	if err != nil {
-		return "", err
+		return Rendered{}, err
+	}
+	r, err := NewRenderer(*h)
+	if err != nil {
+		return Rendered{}, err
 	}
+	return r.GenerateContext(ctx, email)
+}
 
-	// Generate the email from Golang template
+// parseTemplate parses a theme template with all the functions available to themes in the sandbox, if any
+func parseTemplate(tplt string, sandbox *Sandbox) (*template.Template, error) {
 	// Allow usage of simple function from sprig : https://github.com/Masterminds/sprig
-	t, err := template.New("hermes").Funcs(sprig.FuncMap()).Funcs(templateFuncs).Funcs(template.FuncMap{
+	return template.New("hermes").Funcs(sandbox.funcs()).Funcs(templateFuncs).Funcs(template.FuncMap{
 		"safe": func(s string) template.HTML { return template.HTML(s) }, // Used for keeping comments in generated template
 	}).Parse(tplt)
+}
+
+// parseThemeTemplate parses the HTML or the plain text template of the theme,
+// with the stylesheet, the partial templates of hermes and of the theme and the blocks it overrides, if any
+func parseThemeTemplate(theme Theme, html bool, sandbox *Sandbox) (*template.Template, error) {
+	tplt := theme.PlainTextTemplate()
+	if html {
+		tplt = theme.HTMLTemplate()
+	}
+	t, err := parseTemplate(tplt, sandbox)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if stylesheet, ok := theme.(StylesheetTheme); ok {
+		_, err = t.New("stylesheet").Parse(stylesheet.Stylesheet())
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range sortedKeys(msoPartials) {
+		_, err = t.New(name).Parse(msoPartials[name])
+		if err != nil {
+			return nil, err
+		}
 	}
-	var b bytes.Buffer
-	err = t.Execute(&b, Template{*h, email})
+	if partials, ok := theme.(PartialsTheme); ok {
+		for _, name := range sortedKeys(partials.Partials()) {
+			_, err = t.New(name).Parse(partials.Partials()[name])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if extended, ok := theme.(*ExtendedTheme); ok {
+		err = overrideBlocks(t, extended.overrides(html), sandbox)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// templateData builds the root object given to templates, with default values of the email
+// Given hermes configuration is expected to already have its default values
+func templateData(h Hermes, email Email) (Template, error) {
+	err := setDefaultEmailValues(&email)
 	if err != nil {
-		return "", err
+		return Template{}, err
+	}
+	_, err = inlineImages(h, email)
+	if err != nil {
+		return Template{}, err
+	}
+	if strings.Contains(strings.ToLower(h.CustomCSS), "</style") {
+		return Template{}, fmt.Errorf("hermes: custom CSS cannot contain </style>")
+	}
+	var errs ValidationErrors
+	h.ThemeOptions.validate(&errs, "themeOptions")
+	if len(errs) > 0 {
+		return Template{}, fmt.Errorf("hermes: %v", errs[0])
+	}
+	given := h.ThemeOptions
+	h.ThemeOptions, err = themeOptions(h)
+	if err != nil {
+		return Template{}, err
+	}
+	for i, action := range email.Body.Actions {
+		action.Button.validateColors(&errs, fmt.Sprintf("body.actions[%d].button", i))
+	}
+	email.Body.Actions = readableButtons(h.ThemeOptions, email.Body.Actions)
+	// Actions are a copy made by readableButtons, disallowed links can be rewritten in place
+	h.URLPolicy.apply(&errs, &h.Product, email.Body.Actions)
+	freeMarkdown, err := renderMarkdown(string(email.Body.FreeMarkdown), h, &errs, "body.freeMarkdown")
+	if err != nil {
+		return Template{}, err
+	}
+	inline, err := inlineMarkdown(h, email.Body, &errs)
+	if err != nil {
+		return Template{}, err
+	}
+	if len(errs) > 0 {
+		return Template{}, errs
+	}
+	var warnings ValidationErrors
+	if h.ContrastPolicy != ContrastIgnore {
+		warnings = checkContrast(given, h.ThemeOptions, email.Body.Actions)
+	}
+	if h.ContrastPolicy == ContrastEnforce && len(warnings) > 0 {
+		return Template{}, warnings
+	}
+	if h.Product.LogoFile != nil {
+		h.Product.Logo = h.Product.LogoFile.URL()
+	}
+	if h.Product.DarkLogoFile != nil {
+		h.Product.DarkLogo = h.Product.DarkLogoFile.URL()
+	}
+	return Template{Hermes: h, Email: email, warnings: warnings, freeMarkdown: freeMarkdown, inline: inline}, nil
+}
+
+// inlineMarkdown converts the texts of the body from inline markdown to HTML, by text, when enabled
+func inlineMarkdown(h Hermes, body Body, errs *ValidationErrors) (map[string]template.HTML, error) {
+	if !body.InlineMarkdown {
+		return nil, nil
+	}
+	type text struct {
+		field string
+		value string
+	}
+	var texts []text
+	for i, intro := range body.Intros {
+		texts = append(texts, text{fmt.Sprintf("body.intros[%d]", i), intro})
+	}
+	for i, outro := range body.Outros {
+		texts = append(texts, text{fmt.Sprintf("body.outros[%d]", i), outro})
+	}
+	for i, entry := range body.Dictionary {
+		texts = append(texts, text{fmt.Sprintf("body.dictionary[%d].value", i), entry.Value})
 	}
+	for i, row := range body.Table.Data {
+		for j, cell := range row {
+			texts = append(texts, text{fmt.Sprintf("body.table.data[%d][%d].value", i, j), cell.Value})
+		}
+	}
+	inline := make(map[string]template.HTML, len(texts))
+	for _, t := range texts {
+		converted, err := renderInlineMarkdown(t.value, h, errs, t.field)
+		if err != nil {
+			return nil, err
+		}
+		inline[t.value] = converted
+	}
+	return inline, nil
+}
 
-	res := b.String()
-	if h.DisableCSSInlining {
-		return res, nil
+// inlineImages returns all the images to embed in the email, checking they can be embedded
+func inlineImages(h Hermes, email Email) ([]*File, error) {
+	var images []*File
+	if h.Product.LogoFile != nil {
+		images = append(images, h.Product.LogoFile)
+	}
+	if h.Product.DarkLogoFile != nil {
+		images = append(images, h.Product.DarkLogoFile)
 	}
+	images = append(images, email.Body.Images...)
+	ids := make(map[string]bool)
+	for _, image := range images {
+		err := validateImage(image)
+		if err != nil {
+			return nil, err
+		}
+		if ids[image.ContentID()] {
+			return nil, fmt.Errorf("hermes: several inline images are named %q", image.Name)
+		}
+		ids[image.ContentID()] = true
+	}
+	return images, nil
+}
 
-	// Inlining CSS
-	prem, err := premailer.NewPremailerFromString(res, premailer.NewOptions())
+// executeTemplate executes the template, within the limits of the sandbox if any, until ctx is done
+func executeTemplate(ctx context.Context, w io.Writer, t *template.Template, data Template) error {
+	ctx, cancel := data.Hermes.Sandbox.context(ctx)
+	defer cancel()
+	return data.Hermes.Sandbox.execute(ctx, w, t, data)
+}
+
+// pipeTemplate executes the template in the background and streams its result
+// The returned reader must be consumed or closed by the caller
+func pipeTemplate(ctx context.Context, t *template.Template, data Template) *io.PipeReader {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(executeTemplate(ctx, pw, t, data))
+	}()
+	return pr
+}
+
+// executeHTML generates the HTML email from a parsed template, inlines CSS unless disabled, and writes it to w
+func executeHTML(ctx context.Context, w io.Writer, t *template.Template, data Template) error {
+	if data.Hermes.DisableCSSInlining && data.Hermes.CustomCSS == "" {
+		return executeTemplate(ctx, w, t, data)
+	}
+
+	// Inlining CSS, parsing the document while it is generated
+	r := pipeTemplate(ctx, t, data)
+	defer r.Close()
+	doc, err := goquery.NewDocumentFromReader(r)
 	if err != nil {
-		return "", err
+		return err
+	}
+	if data.Hermes.CustomCSS != "" {
+		// Appended after the stylesheet of the theme, so that its rules win over the theme ones
+		doc.Find("head").AppendHtml(`<style type="text/css">` + data.Hermes.CustomCSS + `</style>`)
+	}
+	var res string
+	if data.Hermes.DisableCSSInlining {
+		res, err = doc.Html()
+	} else {
+		res, err = premailer.NewPremailer(doc, premailer.NewOptions()).Transform()
 	}
-	html, err := prem.Transform()
 	if err != nil {
-		return "", err
+		return err
+	}
+	_, err = io.WriteString(w, res)
+	return err
+}
+
+// executePlainText generates the plain text email from a parsed template and writes it to w
+func executePlainText(ctx context.Context, w io.Writer, t *template.Template, data Template) error {
+	// Converting HTML to text, parsing the document while it is generated
+	r := pipeTemplate(ctx, t, data)
+	defer r.Close()
+	doc, err := html.Parse(r)
+	if err != nil {
+		return err
+	}
+	res, err := html2text.FromHTMLNode(doc, html2text.Options{PrettyTables: true})
+	if err != nil {
+		return err
 	}
-	return html, nil
+	_, err = io.WriteString(w, res)
+	return err
 }
@@ -359,7 +359,7 @@ func (ed *WithFreeMarkdownContent) assertHTMLContent(t *testing.T, r string) {
 	assert.Contains(t, r, "Yours truly", "Should find signature with 'Yours truly' which is default")
 	assert.Contains(t, r, "Jon Snow", "Should find title with 'Jon Snow'")
 	assert.Contains(t, r, "<em>Hermes</em> service will shutdown", "Should find quote as HTML formatted content")
-	assert.Contains(t, r, "<td align=\"center\">2AM to 3AM</td>", "Should find cell content as HTML formatted content")
+	assert.Regexp(t, `<td align="center" style="[^"]*">2AM to 3AM</td>`, r, "Should find cell content as HTML formatted content")
 	assert.Contains(t, r, "<a href=\"mailto:support@hermes-example.com\">support@hermes-example.com</a>", "Should find link of mailto as HTML formatted content")
 	assert.Contains(t, r, "An intro that should be kept even with FreeMarkdown", "Should find intro even with FreeMarkdown")
 	assert.Contains(t, r, "An outro that should be kept even with FreeMarkdown", "Should find outro even with FreeMarkdown")
@@ -445,6 +445,14 @@ func checkExample(t *testing.T, ex Example) {
 
 	// Then asserting plain text is OK
 	ex.assertPlainTextContent(t, r)
+
+	// When generating both versions at once
+	rendered, err := h.Generate(email)
+	assert.Nil(t, err)
+
+	// Then asserting both versions are OK
+	ex.assertHTMLContent(t, rendered.HTML)
+	ex.assertPlainTextContent(t, rendered.Text)
 }
 
 ////////////////////////////////////////////
@@ -514,3 +522,47 @@ func TestHermes_Default(t *testing.T) {
 	assert.Equal(t, email.Body.Signature, "Yours truly")
 	assert.Empty(t, email.Body.Title)
 }
+
+// fieldsTheme is a theme with fields, unlike bundled themes
+type fieldsTheme struct {
+	Theme
+	HTML string
+}
+
+func (t *fieldsTheme) HTMLTemplate() string      { return t.HTML }
+func (t *fieldsTheme) PlainTextTemplate() string { return t.HTML }
+
+func TestHermes_DefaultWithThemeFields(t *testing.T) {
+	theme := &fieldsTheme{Theme: new(Flat), HTML: "<p>{{ .Email.Body.Name }}</p>"}
+	h := Hermes{Theme: theme}
+	err := setDefaultHermesValues(&h)
+	assert.Nil(t, err)
+	assert.Equal(t, theme, h.Theme, "Theme should be kept as is")
+
+	res, err := h.GenerateHTML(Email{Body: Body{Name: "Jon Snow"}})
+	assert.Nil(t, err)
+	assert.Contains(t, res, "<p>Jon Snow</p>")
+}
+
+func TestHermes_CustomCSS(t *testing.T) {
+	for _, theme := range []Theme{new(Default), new(Flat)} {
+		h, email := (&SimpleExample{theme}).getExample()
+		email.Body.Actions[0].Button.Color = ""
+		h.DisableCSSInlining = false
+		h.CustomCSS = ".button { background-color: #FF5722; border-radius: 0; } h1 { font-family: Georgia, serif; }"
+		res, err := h.GenerateHTML(email)
+		assert.Nil(t, err)
+		assert.Regexp(t, `class="button" style="[^"]*background-color:\s*#FF5722`, res, "Custom CSS should override the stylesheet of %s", theme.Name())
+		assert.Regexp(t, `<h1 style="[^"]*font-family:\s*Georgia, serif`, res)
+		assert.NotContains(t, res, "<style type=\"text/css\">.button", "Custom CSS should be inlined")
+
+		h.DisableCSSInlining = true
+		res, err = h.GenerateHTML(email)
+		assert.Nil(t, err)
+		assert.Contains(t, res, `<style type="text/css">`+h.CustomCSS+`</style></head>`, "Custom CSS should be appended to the stylesheet when not inlined")
+
+		h.CustomCSS = "p { color: red; }</style><script>alert(1)</script>"
+		_, err = h.GenerateHTML(email)
+		assert.NotNil(t, err)
+	}
+}
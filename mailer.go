@@ -0,0 +1,340 @@
+package hermes
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Mailer is an interface to implement to send messages
+type Mailer interface {
+	// Send sends all the given messages, stopping at the first error
+	Send(ctx context.Context, messages ...*Message) error
+}
+
+// SMTPSecurity is the way the connection to an SMTP server is secured
+type SMTPSecurity string
+
+// SMTPStartTLS upgrades the connection with the STARTTLS command, and fails if the server does not support it (default)
+const SMTPStartTLS SMTPSecurity = "starttls"
+
+// SMTPImplicitTLS connects to the server over TLS from the start (usually on port 465)
+const SMTPImplicitTLS SMTPSecurity = "tls"
+
+// SMTPNoTLS never encrypts the connection. Only use it for local relays.
+const SMTPNoTLS SMTPSecurity = "none"
+
+// SMTPAuthMechanism is the mechanism used to authenticate against an SMTP server
+type SMTPAuthMechanism string
+
+// SMTPAuthPlain is the PLAIN authentication mechanism (default when a username is set)
+const SMTPAuthPlain SMTPAuthMechanism = "PLAIN"
+
+// SMTPAuthLogin is the LOGIN authentication mechanism
+const SMTPAuthLogin SMTPAuthMechanism = "LOGIN"
+
+// SMTPAuthCRAMMD5 is the CRAM-MD5 authentication mechanism
+const SMTPAuthCRAMMD5 SMTPAuthMechanism = "CRAM-MD5"
+
+// SMTPMailer is a Mailer sending messages through an SMTP server
+// All the messages given to a single Send call are sent using the same connection.
+type SMTPMailer struct {
+	Host      string            // Host of the SMTP server, e.g. smtp.gmail.com
+	Port      int               // Port of the SMTP server (default to 465 with implicit TLS, 587 otherwise)
+	Username  string            // Username for authentication. No authentication is done when empty
+	Password  string            // Password for authentication
+	Auth      SMTPAuthMechanism // Authentication mechanism (default to PLAIN)
+	Security  SMTPSecurity      // How the connection is secured (default to STARTTLS)
+	TLSConfig *tls.Config       // TLS configuration (server name default to Host)
+	LocalName string            // Hostname sent with the EHLO command (default to localhost)
+	Timeout   time.Duration     // Timeout of a Send call when the context has no deadline (default to 1 minute)
+}
+
+const defaultSMTPTimeout = time.Minute
+
+// Send sends all the given messages through a single connection to the SMTP server
+func (m *SMTPMailer) Send(ctx context.Context, messages ...*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := m.Timeout
+		if timeout == 0 {
+			timeout = defaultSMTPTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for i, msg := range messages {
+		if i > 0 {
+			err = c.Reset()
+			if err != nil {
+				return m.wrapError(ctx, err)
+			}
+		}
+		err = sendMessage(c, msg)
+		if err != nil {
+			return m.wrapError(ctx, err)
+		}
+	}
+	return m.wrapError(ctx, c.Quit())
+}
+
+// dial connects, secures the connection and authenticates against the SMTP server
+func (m *SMTPMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	if m.Host == "" {
+		return nil, errors.New("hermes: SMTP host is empty")
+	}
+	security := m.Security
+	switch security {
+	case "":
+		security = SMTPStartTLS
+	case SMTPStartTLS, SMTPImplicitTLS, SMTPNoTLS:
+	default:
+		return nil, fmt.Errorf("hermes: unsupported SMTP security %q", m.Security)
+	}
+	port := m.Port
+	if port == 0 {
+		port = 587
+		if security == SMTPImplicitTLS {
+			port = 465
+		}
+	}
+	tlsConfig := &tls.Config{}
+	if m.TLSConfig != nil {
+		tlsConfig = m.TLSConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = m.Host
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(port))
+	var d net.Dialer
+	raw, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The whole SMTP session is bound to the context
+	if deadline, ok := ctx.Deadline(); ok {
+		raw.SetDeadline(deadline)
+	}
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			raw.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	var conn net.Conn = &stopOnCloseConn{Conn: raw, stop: stop}
+
+	if security == SMTPImplicitTLS {
+		tlsConn := tls.Client(conn, tlsConfig)
+		err = tlsConn.Handshake()
+		if err != nil {
+			conn.Close()
+			return nil, m.wrapError(ctx, err)
+		}
+		conn = tlsConn
+	}
+
+	c, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return nil, m.wrapError(ctx, err)
+	}
+	err = m.handshake(c, security, tlsConfig)
+	if err != nil {
+		c.Close()
+		return nil, m.wrapError(ctx, err)
+	}
+	return c, nil
+}
+
+func (m *SMTPMailer) handshake(c *smtp.Client, security SMTPSecurity, tlsConfig *tls.Config) error {
+	localName := m.LocalName
+	if localName == "" {
+		localName = "localhost"
+	}
+	err := c.Hello(localName)
+	if err != nil {
+		return err
+	}
+	if security == SMTPStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("hermes: SMTP server does not support STARTTLS")
+		}
+		err = c.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+	if m.Username == "" {
+		return nil
+	}
+	auth, err := m.auth()
+	if err != nil {
+		return err
+	}
+	return c.Auth(auth)
+}
+
+func (m *SMTPMailer) auth() (smtp.Auth, error) {
+	switch m.Auth {
+	case "", SMTPAuthPlain:
+		return smtp.PlainAuth("", m.Username, m.Password, m.Host), nil
+	case SMTPAuthLogin:
+		return &loginAuth{username: m.Username, password: m.Password}, nil
+	case SMTPAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(m.Username, m.Password), nil
+	default:
+		return nil, fmt.Errorf("hermes: unsupported SMTP authentication mechanism %q", m.Auth)
+	}
+}
+
+// wrapError adds the context error to the network error when the context is done
+func (m *SMTPMailer) wrapError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	ctxErr := ctx.Err()
+	if deadline, ok := ctx.Deadline(); ctxErr == nil && ok && !time.Now().Before(deadline) {
+		// The connection deadline may be reached just before the context is marked as done
+		ctxErr = context.DeadlineExceeded
+	}
+	if ctxErr != nil {
+		return fmt.Errorf("hermes: %w: %v", ctxErr, err)
+	}
+	return err
+}
+
+// sendMessage sends a single message on an established SMTP session
+func sendMessage(c *smtp.Client, msg *Message) error {
+	sender, err := msg.Sender()
+	if err != nil {
+		return err
+	}
+	recipients, err := msg.Recipients()
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return errors.New("hermes: message has no recipient")
+	}
+	err = c.Mail(sender)
+	if err != nil {
+		return err
+	}
+	for _, r := range recipients {
+		err = c.Rcpt(r)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = msg.WriteTo(w)
+	if err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// loginAuth implements the LOGIN authentication mechanism, not provided by net/smtp
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like PLAIN, LOGIN sends the password in clear text
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("hermes: unencrypted connection")
+	}
+	return string(SMTPAuthLogin), nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:", "User Name\x00":
+		return []byte(a.username), nil
+	case "Password:", "Password\x00":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("hermes: unexpected LOGIN challenge %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// stopOnCloseConn is a connection stopping the context watcher once closed
+type stopOnCloseConn struct {
+	net.Conn
+	stop chan struct{}
+	once sync.Once
+}
+
+func (c *stopOnCloseConn) Close() error {
+	c.once.Do(func() { close(c.stop) })
+	return c.Conn.Close()
+}
+
+// RecordingMailer is a Mailer keeping sent messages in memory instead of sending them
+// It is meant to be used in unit tests, and is safe for concurrent use.
+type RecordingMailer struct {
+	Err error // When set, Send returns this error without recording messages
+
+	mu       sync.Mutex
+	messages []*Message
+}
+
+// Send records the given messages
+func (m *RecordingMailer) Send(ctx context.Context, messages ...*Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.messages = append(m.messages, messages...)
+	return nil
+}
+
+// Messages returns all the messages recorded so far
+func (m *RecordingMailer) Messages() []*Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Message(nil), m.messages...)
+}
+
+// Reset forgets all the messages recorded so far
+func (m *RecordingMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
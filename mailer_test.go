@@ -0,0 +1,376 @@
+package hermes
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	fakeSMTPUser     = "hermes"
+	fakeSMTPPassword = "s3cr3t"
+)
+
+// fakeSMTPMessage is a message received by the fake SMTP server
+type fakeSMTPMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTPServer is a minimal in-process SMTP server, supporting STARTTLS, implicit TLS and PLAIN/LOGIN/CRAM-MD5 authentication
+type fakeSMTPServer struct {
+	ln          net.Listener
+	tlsConfig   *tls.Config // When set, STARTTLS is supported
+	implicitTLS bool        // When true, connections are secured from the start
+	silent      bool        // When true, the server never answers
+
+	mu          sync.Mutex
+	connections int
+	messages    []fakeSMTPMessage
+}
+
+// startFakeSMTPServer starts listening on a random local port with the given configuration
+func startFakeSMTPServer(t *testing.T, s *fakeSMTPServer) *fakeSMTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ln = ln
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) close() {
+	s.ln.Close()
+}
+
+func (s *fakeSMTPServer) receivedMessages() ([]fakeSMTPMessage, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeSMTPMessage(nil), s.messages...), s.connections
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.connections++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	if s.silent {
+		ioutil.ReadAll(conn)
+		return
+	}
+	secured := false
+	if s.implicitTLS {
+		conn = tls.Server(conn, s.tlsConfig)
+		secured = true
+	}
+	tc := textproto.NewConn(conn)
+	tc.PrintfLine("220 localhost ESMTP fake")
+	var current fakeSMTPMessage
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		arg := strings.TrimSpace(strings.TrimPrefix(line, line[:len(verb)]))
+		switch verb {
+		case "EHLO", "HELO":
+			tc.PrintfLine("250-localhost")
+			if s.tlsConfig != nil && !secured {
+				tc.PrintfLine("250-STARTTLS")
+			}
+			tc.PrintfLine("250-AUTH PLAIN LOGIN CRAM-MD5")
+			tc.PrintfLine("250 8BITMIME")
+		case "STARTTLS":
+			tc.PrintfLine("220 Ready to start TLS")
+			conn = tls.Server(conn, s.tlsConfig)
+			tc = textproto.NewConn(conn)
+			secured = true
+		case "AUTH":
+			if s.authenticate(tc, arg) {
+				tc.PrintfLine("235 Authentication successful")
+			} else {
+				tc.PrintfLine("535 Authentication failed")
+			}
+		case "MAIL":
+			current = fakeSMTPMessage{from: trimAddress(arg, "FROM:")}
+			tc.PrintfLine("250 OK")
+		case "RCPT":
+			current.to = append(current.to, trimAddress(arg, "TO:"))
+			tc.PrintfLine("250 OK")
+		case "DATA":
+			tc.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := ioutil.ReadAll(tc.DotReader())
+			if err != nil {
+				return
+			}
+			current.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, current)
+			s.mu.Unlock()
+			tc.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			tc.PrintfLine("250 OK")
+		case "QUIT":
+			tc.PrintfLine("221 Bye")
+			return
+		default:
+			tc.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) authenticate(tc *textproto.Conn, arg string) bool {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "PLAIN":
+		if len(fields) != 2 {
+			return false
+		}
+		credentials, _ := base64.StdEncoding.DecodeString(fields[1])
+		return string(credentials) == "\x00"+fakeSMTPUser+"\x00"+fakeSMTPPassword
+	case "LOGIN":
+		username := challenge(tc, "Username:")
+		password := challenge(tc, "Password:")
+		return username == fakeSMTPUser && password == fakeSMTPPassword
+	case "CRAM-MD5":
+		nonce := "<" + strconv.FormatInt(time.Now().UnixNano(), 10) + "@localhost>"
+		d := hmac.New(md5.New, []byte(fakeSMTPPassword))
+		d.Write([]byte(nonce))
+		return challenge(tc, nonce) == fakeSMTPUser+" "+hex.EncodeToString(d.Sum(nil))
+	default:
+		return false
+	}
+}
+
+// challenge sends a base64 encoded challenge and returns the decoded response
+func challenge(tc *textproto.Conn, c string) string {
+	tc.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(c)))
+	line, _ := tc.ReadLine()
+	response, _ := base64.StdEncoding.DecodeString(line)
+	return string(response)
+}
+
+// trimAddress extracts the address of a MAIL or RCPT command argument, e.g. `FROM:<hermes@example.com> BODY=8BITMIME`
+func trimAddress(arg, prefix string) string {
+	arg = strings.TrimSpace(arg[len(prefix):])
+	return strings.Trim(strings.Fields(arg)[0], "<>")
+}
+
+// generateTLSConfigs generates a self-signed certificate, returning the server and client TLS configurations
+func generateTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"Hermes"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client := &tls.Config{RootCAs: pool}
+	return server, client
+}
+
+func getTestMessages(t *testing.T) []*Message {
+	first := getTestMessage(t)
+	second := getTestMessage(t)
+	second.To = []string{"Arya Stark <arya@example.com>"}
+	second.Cc, second.Bcc = nil, nil
+	second.Subject = "A second message"
+	return []*Message{first, second}
+}
+
+func TestSMTPMailer_StartTLS(t *testing.T) {
+	serverTLS, clientTLS := generateTLSConfigs(t)
+	for _, auth := range []SMTPAuthMechanism{"", SMTPAuthPlain, SMTPAuthLogin, SMTPAuthCRAMMD5} {
+		s := startFakeSMTPServer(t, &fakeSMTPServer{tlsConfig: serverTLS})
+		m := &SMTPMailer{
+			Host:      "127.0.0.1",
+			Port:      s.port(),
+			Username:  fakeSMTPUser,
+			Password:  fakeSMTPPassword,
+			Auth:      auth,
+			TLSConfig: clientTLS,
+		}
+		err := m.Send(context.Background(), getTestMessages(t)...)
+		assert.Nil(t, err, "Should send messages with %q authentication", auth)
+
+		messages, connections := s.receivedMessages()
+		assert.Equal(t, 1, connections, "Should send all messages in a single connection")
+		if assert.Len(t, messages, 2) {
+			assert.Equal(t, "hermes@example.com", messages[0].from)
+			assert.Equal(t, []string{"jon@example.com", "arya@example.com", "sansa@example.com", "bran@example.com", "hodor@example.com"}, messages[0].to)
+			assert.Equal(t, []string{"arya@example.com"}, messages[1].to, "Recipients of the first message should be reset")
+
+			parsed, err := mail.ReadMessage(strings.NewReader(messages[1].data))
+			assert.Nil(t, err)
+			assert.Equal(t, "A second message", parsed.Header.Get("Subject"))
+		}
+		s.close()
+	}
+}
+
+func TestSMTPMailer_ImplicitTLS(t *testing.T) {
+	serverTLS, clientTLS := generateTLSConfigs(t)
+	s := startFakeSMTPServer(t, &fakeSMTPServer{tlsConfig: serverTLS, implicitTLS: true})
+	defer s.close()
+
+	m := &SMTPMailer{
+		Host:      "127.0.0.1",
+		Port:      s.port(),
+		Username:  fakeSMTPUser,
+		Password:  fakeSMTPPassword,
+		Security:  SMTPImplicitTLS,
+		TLSConfig: clientTLS,
+	}
+	err := m.Send(context.Background(), getTestMessage(t))
+	assert.Nil(t, err)
+	messages, _ := s.receivedMessages()
+	assert.Len(t, messages, 1)
+}
+
+func TestSMTPMailer_StartTLSNotSupported(t *testing.T) {
+	s := startFakeSMTPServer(t, &fakeSMTPServer{})
+	defer s.close()
+
+	m := &SMTPMailer{Host: "127.0.0.1", Port: s.port()}
+	err := m.Send(context.Background(), getTestMessage(t))
+	assert.NotNil(t, err, "Should not send messages in clear text when STARTTLS is required")
+
+	m.Security = SMTPNoTLS
+	err = m.Send(context.Background(), getTestMessage(t))
+	assert.Nil(t, err, "Should send messages in clear text when explicitly asked")
+	messages, _ := s.receivedMessages()
+	assert.Len(t, messages, 1)
+}
+
+func TestSMTPMailer_AuthenticationFailure(t *testing.T) {
+	serverTLS, clientTLS := generateTLSConfigs(t)
+	s := startFakeSMTPServer(t, &fakeSMTPServer{tlsConfig: serverTLS})
+	defer s.close()
+
+	m := &SMTPMailer{
+		Host:      "127.0.0.1",
+		Port:      s.port(),
+		Username:  fakeSMTPUser,
+		Password:  "wrong",
+		TLSConfig: clientTLS,
+	}
+	err := m.Send(context.Background(), getTestMessage(t))
+	assert.NotNil(t, err)
+	messages, _ := s.receivedMessages()
+	assert.Empty(t, messages)
+}
+
+func TestSMTPMailer_ContextTimeout(t *testing.T) {
+	s := startFakeSMTPServer(t, &fakeSMTPServer{silent: true})
+	defer s.close()
+
+	m := &SMTPMailer{Host: "127.0.0.1", Port: s.port(), Security: SMTPNoTLS}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := m.Send(ctx, getTestMessage(t))
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Should wrap the context error: %v", err)
+	assert.True(t, time.Since(start) < 5*time.Second, "Should stop when the context is done")
+}
+
+func TestSMTPMailer_InvalidConfiguration(t *testing.T) {
+	err := new(SMTPMailer).Send(context.Background(), getTestMessage(t))
+	assert.NotNil(t, err, "Should not send without host")
+
+	err = new(SMTPMailer).Send(context.Background())
+	assert.Nil(t, err, "Should do nothing without messages")
+
+	s := startFakeSMTPServer(t, &fakeSMTPServer{})
+	defer s.close()
+	for _, security := range []SMTPSecurity{"TLS", "STARTTLS", "ssl"} {
+		m := &SMTPMailer{Host: "127.0.0.1", Port: s.port(), Username: fakeSMTPUser, Password: fakeSMTPPassword, Auth: SMTPAuthCRAMMD5, Security: security}
+		err = m.Send(context.Background(), getTestMessage(t))
+		assert.EqualError(t, err, fmt.Sprintf("hermes: unsupported SMTP security %q", security))
+	}
+	messages, _ := s.receivedMessages()
+	assert.Empty(t, messages, "Should not send messages in clear text with an unknown security")
+}
+
+func TestRecordingMailer(t *testing.T) {
+	m := new(RecordingMailer)
+	var mailer Mailer = m
+
+	messages := getTestMessages(t)
+	err := mailer.Send(context.Background(), messages...)
+	assert.Nil(t, err)
+	assert.Equal(t, messages, m.Messages())
+
+	m.Reset()
+	assert.Empty(t, m.Messages())
+
+	m.Err = errors.New("unavailable")
+	err = mailer.Send(context.Background(), messages...)
+	assert.Equal(t, m.Err, err)
+	assert.Empty(t, m.Messages())
+
+	m.Err = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = mailer.Send(ctx, messages...)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,191 @@
+package hermes
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/imdario/mergo"
+	"github.com/russross/blackfriday/v2"
+)
+
+// markdownStyles are the inline styles of the elements rendered from markdown, built from the options of the theme
+type markdownStyles struct {
+	table, header, cell, image, quote, pre, code string
+}
+
+func newMarkdownStyles(o ThemeOptions) markdownStyles {
+	return markdownStyles{
+		table:  "width: 100%; margin: 0 0 21px; border-collapse: collapse;",
+		header: fmt.Sprintf("padding: 8px 10px; border: 1px solid %s; background-color: %s; color: %s; font-size: 13px; font-weight: bold;", o.BorderColor, o.MutedBackgroundColor, o.SecondaryColor),
+		cell:   fmt.Sprintf("padding: 8px 10px; border: 1px solid %s; color: %s; font-size: 15px; line-height: 18px;", o.BorderColor, o.TextColor),
+		image:  "max-width: 100%; height: auto; border: 0;",
+		quote:  fmt.Sprintf("margin: 0 0 21px; padding: 0 0 0 16px; border-left: 4px solid %s; color: %s; font-style: italic;", o.PrimaryColor, o.TextColor),
+		pre:    fmt.Sprintf("margin: 0 0 21px; padding: 12px 16px; border: 1px solid %s; border-radius: %dpx; background-color: %s; white-space: pre-wrap; word-wrap: break-word; font-family: %s; font-size: 13px; line-height: 18px; color: %s;", o.BorderColor, o.BorderRadius, o.MutedBackgroundColor, o.MonospaceFontFamily, o.SecondaryColor),
+		code:   fmt.Sprintf("padding: 2px 4px; border-radius: %dpx; background-color: %s; font-family: %s; font-size: 13px;", o.BorderRadius, o.MutedBackgroundColor, o.MonospaceFontFamily),
+	}
+}
+
+// trusted returns the attributes generated by the renderer, allowed whatever the HTML policy, by key=value
+func (s markdownStyles) trusted() map[string]bool {
+	trusted := map[string]bool{
+		"width=100%": true, "cellpadding=0": true, "cellspacing=0": true,
+		"align=left": true, "align=right": true, "align=center": true,
+	}
+	for _, style := range []string{s.table, s.header, s.cell, s.image, s.quote, s.pre, s.code} {
+		trusted["style="+style] = true
+	}
+	return trusted
+}
+
+// emailRenderer renders markdown to HTML displayed alike by all email clients, Outlook included:
+// tables are bordered, images fit the width of the email, block quotes and code blocks are highlighted,
+// all styled inline with the options of the theme.
+type emailRenderer struct {
+	*blackfriday.HTMLRenderer
+	styles markdownStyles
+}
+
+func newEmailRenderer(options ThemeOptions) *emailRenderer {
+	return &emailRenderer{
+		HTMLRenderer: blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{Flags: blackfriday.CommonHTMLFlags}),
+		styles:       newMarkdownStyles(options),
+	}
+}
+
+// RenderNode renders the elements styled for emails, and lets the HTML renderer render the other ones
+func (r *emailRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	switch node.Type {
+	case blackfriday.Table:
+		if entering {
+			fmt.Fprintf(w, "\n"+`<table width="100%%" cellpadding="0" cellspacing="0" style="%s">`+"\n", r.styles.table)
+		} else {
+			io.WriteString(w, "</table>\n")
+		}
+	case blackfriday.TableCell:
+		tag, style := "td", r.styles.cell
+		if node.IsHeader {
+			tag, style = "th", r.styles.header
+		}
+		if !entering {
+			fmt.Fprintf(w, "</%s>\n", tag)
+			break
+		}
+		fmt.Fprintf(w, "<%s", tag)
+		if align := cellAlignment(node.Align); align != "" {
+			fmt.Fprintf(w, ` align="%s"`, align)
+		}
+		fmt.Fprintf(w, ` style="%s">`, style)
+	case blackfriday.Image:
+		// The HTML renderer writes the tag, and the alt text of the image as text, unless inside another image
+		var b bytes.Buffer
+		status := r.HTMLRenderer.RenderNode(&b, node, entering)
+		w.Write(bytes.Replace(b.Bytes(), []byte("<img "), []byte(`<img style="`+r.styles.image+`" `), 1))
+		return status
+	case blackfriday.BlockQuote:
+		if entering {
+			fmt.Fprintf(w, "\n<blockquote style=\"%s\">\n", r.styles.quote)
+		} else {
+			io.WriteString(w, "</blockquote>\n")
+		}
+	case blackfriday.CodeBlock:
+		fmt.Fprintf(w, "\n<pre style=\"%s\"><code", r.styles.pre)
+		if language := strings.Fields(string(node.Info)); len(language) > 0 {
+			fmt.Fprintf(w, ` class="language-%s"`, template.HTMLEscapeString(language[0]))
+		}
+		fmt.Fprintf(w, ">%s</code></pre>\n", template.HTMLEscapeString(string(node.Literal)))
+	case blackfriday.Code:
+		fmt.Fprintf(w, `<code style="%s">%s</code>`, r.styles.code, template.HTMLEscapeString(string(node.Literal)))
+	default:
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+	return blackfriday.GoToNext
+}
+
+// cellAlignment returns the align attribute of a table cell
+func cellAlignment(align blackfriday.CellAlignFlags) string {
+	switch align {
+	case blackfriday.TableAlignmentLeft:
+		return "left"
+	case blackfriday.TableAlignmentRight:
+		return "right"
+	case blackfriday.TableAlignmentCenter:
+		return "center"
+	}
+	return ""
+}
+
+// inlineRenderer renders markdown as the content of a paragraph or of a table cell, e.g. **bold**, [links](https://...) and `code`
+// Blocks are rendered as their content, separated by line breaks.
+type inlineRenderer struct {
+	*emailRenderer
+	blocks int // Number of blocks already rendered
+}
+
+// RenderNode renders the content of blocks, and lets the email renderer render inline elements
+func (r *inlineRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	switch node.Type {
+	case blackfriday.Document, blackfriday.List, blackfriday.Item, blackfriday.BlockQuote, blackfriday.HorizontalRule,
+		blackfriday.Table, blackfriday.TableHead, blackfriday.TableBody, blackfriday.TableRow:
+		return blackfriday.GoToNext
+	case blackfriday.Paragraph, blackfriday.Heading, blackfriday.TableCell, blackfriday.CodeBlock, blackfriday.HTMLBlock:
+		if !entering {
+			return blackfriday.GoToNext
+		}
+		if r.blocks > 0 {
+			io.WriteString(w, "<br/>")
+		}
+		r.blocks++
+		switch node.Type {
+		case blackfriday.CodeBlock:
+			fmt.Fprintf(w, `<code style="%s">%s</code>`, r.styles.code, template.HTMLEscapeString(strings.TrimSpace(string(node.Literal))))
+		case blackfriday.HTMLBlock:
+			w.Write(node.Literal)
+		}
+		return blackfriday.GoToNext
+	}
+	return r.emailRenderer.RenderNode(w, node, entering)
+}
+
+// renderMarkdown converts markdown to HTML styled with the theme options of h, and sanitized with its policies
+// Disallowed URLs are reported on field in errs, if any, unless the URL policy of h rewrites them.
+func renderMarkdown(markdown string, h Hermes, errs *ValidationErrors, field string) (template.HTML, error) {
+	return render(markdown, h, false, errs, field)
+}
+
+// renderInlineMarkdown converts markdown to inline HTML, like renderMarkdown
+func renderInlineMarkdown(markdown string, h Hermes, errs *ValidationErrors, field string) (template.HTML, error) {
+	return render(markdown, h, true, errs, field)
+}
+
+// render converts markdown to HTML, or to inline HTML
+func render(markdown string, h Hermes, inline bool, errs *ValidationErrors, field string) (template.HTML, error) {
+	if markdown == "" {
+		return "", nil
+	}
+	extensions := blackfriday.CommonExtensions
+	if h.MarkdownExtensions != nil {
+		extensions = *h.MarkdownExtensions
+	}
+	// Themes without options are styled like the default theme
+	options := h.ThemeOptions
+	err := mergo.Merge(&options, new(Default).DefaultOptions())
+	if err != nil {
+		return "", err
+	}
+	r := newEmailRenderer(options)
+	var renderer blackfriday.Renderer = r
+	if inline {
+		renderer = &inlineRenderer{emailRenderer: r}
+	}
+	content := blackfriday.Run([]byte(markdown), blackfriday.WithRenderer(renderer), blackfriday.WithExtensions(extensions))
+	policy := DefaultHTMLPolicy()
+	if h.HTMLPolicy != nil {
+		policy = *h.HTMLPolicy
+	}
+	s := &sanitizer{policy: policy, urls: h.URLPolicy, trusted: r.styles.trusted(), errs: errs, field: field}
+	res, err := s.sanitize(string(content))
+	return template.HTML(res), err
+}
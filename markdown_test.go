@@ -0,0 +1,110 @@
+package hermes
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/russross/blackfriday/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const styledMarkdown = `> Hermes service will shutdown
+
+| Service   | Downtime   |
+| :-------- | ---------: |
+| Service A | 2AM to 3AM |
+
+![Chart](https://hermes-example.com/chart.png "Usage")
+
+Run ` + "`hermes render`" + ` or:
+
+` + "```go" + `
+h := hermes.Hermes{}
+` + "```" + `
+`
+
+func TestRenderMarkdown(t *testing.T) {
+	h := Hermes{ThemeOptions: ThemeOptions{PrimaryColor: "#FF6600", TextColor: "#333333", SecondaryColor: "#111111", MonospaceFontFamily: "Menlo, monospace", BorderRadius: 4}}
+	res, err := renderMarkdown(styledMarkdown, h, nil, "")
+	assert.Nil(t, err)
+	assert.Contains(t, res, `<blockquote style="margin: 0 0 21px; padding: 0 0 0 16px; border-left: 4px solid #FF6600; color: #333333; font-style: italic;">`)
+	assert.Contains(t, res, `<table width="100%" cellpadding="0" cellspacing="0" style="width: 100%; margin: 0 0 21px; border-collapse: collapse;">`)
+	assert.Contains(t, res, `<th align="left" style="padding: 8px 10px; border: 1px solid #EDEFF2; background-color: #F2F4F6; color: #111111; font-size: 13px; font-weight: bold;">Service</th>`)
+	assert.Contains(t, res, `<td align="right" style="padding: 8px 10px; border: 1px solid #EDEFF2; color: #333333; font-size: 15px; line-height: 18px;">2AM to 3AM</td>`)
+	assert.Contains(t, res, `<img style="max-width: 100%; height: auto; border: 0;" src="https://hermes-example.com/chart.png" alt="Chart" title="Usage"/>`)
+	assert.Contains(t, res, `<code style="padding: 2px 4px; border-radius: 4px; background-color: #F2F4F6; font-family: Menlo, monospace; font-size: 13px;">hermes render</code>`)
+	assert.Regexp(t, `<pre style="[^"]*font-family: Menlo, monospace;[^"]*"><code class="language-go">h := hermes.Hermes{}\n</code></pre>`, res)
+
+	policy := HTMLPolicy{Elements: []string{"blockquote", "p", "table", "thead", "tbody", "tr", "th", "td", "img", "pre", "code"}}
+	h.HTMLPolicy = &policy
+	styled, err := renderMarkdown(styledMarkdown+`<p style="color: red">Hello</p>`, h, nil, "")
+	assert.Nil(t, err)
+	assert.Contains(t, styled, `<th align="left" style="padding: 8px 10px;`, "Styles of the renderer should be kept whatever the policy")
+	assert.Contains(t, styled, `<img style="max-width: 100%; height: auto; border: 0;"/>`, "Attributes of images not allowed by the policy should be removed")
+	assert.Contains(t, styled, `<p>Hello</p>`, "Styles of the content should be removed")
+}
+
+func TestHermes_MarkdownExtensions(t *testing.T) {
+	email := Email{Body{FreeMarkdown: "| Service   | Downtime   |\n| --------- | ---------- |\n| Service A | 2AM to 3AM |\n"}}
+	h := Hermes{}
+	res, err := h.GenerateHTML(email)
+	assert.Nil(t, err)
+	assert.Contains(t, res, "2AM to 3AM</td>", "Tables should be enabled by default")
+
+	extensions := blackfriday.CommonExtensions &^ blackfriday.Tables
+	h = Hermes{MarkdownExtensions: &extensions}
+	res, err = h.GenerateHTML(email)
+	assert.Nil(t, err)
+	assert.NotContains(t, res, "2AM to 3AM</td>", "Disabled extensions should not be rendered")
+	assert.Contains(t, res, "| Service A | 2AM to 3AM |")
+
+	extensions = blackfriday.NoExtensions
+	res, err = h.GenerateHTML(Email{Body{FreeMarkdown: "See https://hermes-example.com/docs\n\n| Service   | Downtime   |\n| --------- | ---------- |\n| Service A | 2AM to 3AM |\n"}})
+	assert.Nil(t, err)
+	assert.NotContains(t, res, `<a href="https://hermes-example.com/docs"`, "All extensions should be disabled")
+	assert.NotContains(t, res, "2AM to 3AM</td>", "All extensions should be disabled")
+}
+
+func TestRenderInlineMarkdown(t *testing.T) {
+	res, err := renderInlineMarkdown("Welcome **Jon**, see [docs](https://hermes-example.com/docs) and run `hermes render`", Hermes{}, nil, "")
+	assert.Nil(t, err)
+	assert.Equal(t, template.HTML(`Welcome <strong>Jon</strong>, see <a href="https://hermes-example.com/docs">docs</a> and run <code style="padding: 2px 4px; border-radius: 3px; background-color: #F2F4F6; font-family: Consolas, monaco, monospace; font-size: 13px;">hermes render</code>`), res)
+
+	res, err = renderInlineMarkdown("# First\n\n- a\n- b", Hermes{}, nil, "")
+	assert.Nil(t, err)
+	assert.Equal(t, template.HTML("First<br/>a<br/>b"), res, "Blocks should be rendered as their content, separated by line breaks")
+}
+
+func TestHermes_InlineMarkdown(t *testing.T) {
+	body := Body{
+		Intros:     []string{"Welcome to **Hermes**"},
+		Outros:     []string{"Read the [docs](https://hermes-example.com/docs)"},
+		Dictionary: []Entry{{Key: "Plan", Value: "*Pro*"}},
+		Table:      Table{Data: [][]Entry{{{Key: "Command", Value: "`hermes render`"}}}},
+	}
+	h := Hermes{}
+	for _, theme := range []Theme{new(Default), new(Flat)} {
+		h.Theme = theme
+		res, err := h.GenerateHTML(Email{body})
+		assert.Nil(t, err)
+		assert.Contains(t, res, "Welcome to **Hermes**", "Markdown should not be rendered unless enabled")
+
+		enabled := body
+		enabled.InlineMarkdown = true
+		res, err = h.GenerateHTML(Email{enabled})
+		assert.Nil(t, err)
+		assert.Contains(t, res, "Welcome to <strong>Hermes</strong>", theme.Name())
+		assert.Contains(t, res, `Read the <a href="https://hermes-example.com/docs"`, theme.Name())
+		assert.Contains(t, res, "<em>Pro</em>", theme.Name())
+		assert.Regexp(t, `<code style="[^"]*">hermes render</code>`, res, theme.Name())
+
+		text, err := h.GeneratePlainText(Email{enabled})
+		assert.Nil(t, err)
+		assert.Contains(t, text, "Welcome to *Hermes*", theme.Name())
+		assert.Contains(t, text, "Read the docs ( https://hermes-example.com/docs )", theme.Name())
+		assert.NotContains(t, text, "<", theme.Name())
+	}
+
+	_, err := h.GenerateHTML(Email{Body{Intros: []string{"[Click](javascript:alert)"}, InlineMarkdown: true}})
+	assert.Equal(t, ValidationErrors{{Field: "body.intros[0]", Message: `URL "javascript:alert" has a disallowed scheme "javascript"`}}, err)
+}
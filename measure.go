@@ -0,0 +1,54 @@
+package hermes
+
+import (
+	"math"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// narrowCharWidth is the average width of narrow characters, e.g. latin letters, in a sans-serif font, in em
+const narrowCharWidth = 0.6
+
+// columns measures characters in columns, narrow ones being one column wide and wide ones two
+// Ambiguous characters are narrow, whatever the locale of the environment.
+var columns = &runewidth.Condition{EastAsianWidth: false}
+
+// Characters changing the width of the ones around them
+const (
+	zeroWidthJoiner    = '\u200d' // Joins emoji into a single one, e.g. family emoji
+	emojiPresentation  = '\ufe0f' // Displays the previous character as an emoji, e.g. ✔️
+	regionalIndicatorA = '\U0001F1E6'
+	regionalIndicatorZ = '\U0001F1FF'
+)
+
+// TextWidth estimates the width in pixels of a single line text, displayed with a sans-serif font of fontSize pixels
+// Narrow characters, e.g. latin or arabic letters, are 0.6 em wide, and wide ones, e.g. CJK ideographs and emoji, twice as wide.
+// Combining marks and emoji sequences, e.g. flags, are measured as the single character they are displayed as.
+func TextWidth(text string, fontSize int) int {
+	width := 0
+	previous, joined, indicator := 0, false, false
+	for _, r := range text {
+		switch {
+		case joined:
+			// Joined to the previous emoji
+			joined = false
+		case r == zeroWidthJoiner:
+			joined = true
+		case r == emojiPresentation:
+			if previous == 1 {
+				width++
+				previous = 2
+			}
+		case r >= regionalIndicatorA && r <= regionalIndicatorZ:
+			// Flags are pairs of regional indicators
+			if !indicator {
+				width += 2
+			}
+			indicator = !indicator
+		default:
+			previous = columns.RuneWidth(r)
+			width += previous
+		}
+	}
+	return int(math.Ceil(float64(width) * narrowCharWidth * float64(fontSize)))
+}
@@ -0,0 +1,57 @@
+package hermes
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextWidth(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		fontSize int
+		want     int
+	}{
+		{"Empty text", "", 15, 0},
+		{"ASCII characters are 9px wide at 15px", "Confirm your account", 15, 180},
+		{"Width scales with the font size", "Confirm your account", 30, 360},
+		{"Accented letters are narrow", "Vérifier", 15, 72},
+		{"Combining marks have no width", "Vérifier", 15, 72},
+		{"Japanese characters are wide", "確認する", 15, 72},
+		{"Arabic letters are narrow", "تأكيد", 15, 45},
+		{"Emoji are wide", "👍", 15, 18},
+		{"Emoji presentation makes text symbols wide", "✔️", 15, 18},
+		{"Joined emoji are a single emoji", "👨‍👩‍👧", 15, 18},
+		{"Flags are a single emoji", "🇫🇷🇯🇵", 15, 36},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, TextWidth(test.text, test.fontSize))
+		})
+	}
+}
+
+func TestBundledThemesButtonWidth(t *testing.T) {
+	widths := map[string]int{
+		"Confirm": 200,
+		"アカウントを確認してください":                20 + 14*18,
+		"تأكيد الحساب":                  200,
+		strings.Repeat("Confirm ", 100): 500,
+	}
+	for _, theme := range Themes() {
+		for text, width := range widths {
+			h := Hermes{Theme: theme}
+			res, err := h.GenerateHTML(Email{Body{Actions: []Action{{Button: Button{Text: text, Link: "https://hermes-example.com/confirm"}}}}})
+			assert.Nil(t, err)
+			assert.Regexp(t, `<v:roundrect [^>]*style="[^"]*width:`+strconv.Itoa(width)+`px;`, res, "Outlook button of %q should be %dpx wide in %s", text, width, theme.Name())
+			if theme.Name() == "flat" {
+				assert.Regexp(t, `class="button" style="[^"]*width: ?100%;`, res, "Buttons of the flat theme should fill the width of the email")
+			} else {
+				assert.Regexp(t, `class="button" style="[^"]*width: ?`+strconv.Itoa(width)+`px;?"`, res, "Button of %q should be %dpx wide in %s", text, width, theme.Name())
+			}
+		}
+	}
+}
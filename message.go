@@ -0,0 +1,434 @@
+package hermes
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// maxHeaderLineLength is the recommended maximum length of a header line (RFC 5322 section 2.1.1)
+const maxHeaderLineLength = 78
+
+// Message is a complete email, made of a rendered email and its headers
+// It is serialized as a RFC 5322 multipart/alternative message,
+// nested in multipart/related and multipart/mixed entities for inline images and attachments.
+type Message struct {
+	From      string    // Sender address, e.g. `Hermes <hermes@example.com>`
+	To        []string  // Recipient addresses
+	Cc        []string  // Carbon copy addresses
+	Bcc       []string  // Blind carbon copy addresses, never written in headers
+	ReplyTo   []string  // Addresses replies should be sent to
+	Subject   string    // Subject of the email, encoded when not ASCII
+	Date      time.Time // Date of the email (default to now)
+	MessageID string    // Message-ID header without angle brackets (default to a random identifier on the sender domain)
+	Rendered  Rendered  // The HTML and plain text versions of the email, with their inline images
+
+	Attachments       []*File // Files attached to the message
+	MaxAttachmentSize int64   // Maximum total size of attachments, in bytes (default to DefaultMaxAttachmentSize)
+
+	DKIM *DKIMOptions // When set, the message is signed with DKIM
+}
+
+// DefaultMaxAttachmentSize is the default maximum total size of the attachments of a message
+const DefaultMaxAttachmentSize = 10 << 20
+
+// maxBase64LineLength is the maximum length of a line of base64 encoded content (RFC 2045 section 6.8)
+const maxBase64LineLength = 76
+
+// header is a single header field of a message
+type header struct {
+	key   string
+	value string
+}
+
+// Recipients returns the addresses of all the recipients of the message (To, Cc and Bcc)
+func (m *Message) Recipients() ([]string, error) {
+	var recipients []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		addresses, err := parseAddressList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addresses {
+			recipients = append(recipients, a.Address)
+		}
+	}
+	return recipients, nil
+}
+
+// Sender returns the address of the sender of the message
+func (m *Message) Sender() (string, error) {
+	if m.From == "" {
+		return "", errors.New("hermes: message has no sender")
+	}
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return "", fmt.Errorf("hermes: invalid sender %q: %v", m.From, err)
+	}
+	return from.Address, nil
+}
+
+// WriteTo serializes the message to w, as an .eml file
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	if m.DKIM != nil {
+		// The whole message is needed to compute its signature
+		var b bytes.Buffer
+		err := m.write(&b)
+		if err != nil {
+			return 0, err
+		}
+		signed, err := SignDKIM(b.Bytes(), *m.DKIM)
+		if err != nil {
+			return 0, err
+		}
+		n, err := w.Write(signed)
+		return int64(n), err
+	}
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	err := m.write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	return cw.n, err
+}
+
+func (m *Message) write(w io.Writer) error {
+	headers, err := m.headers()
+	if err != nil {
+		return err
+	}
+	err = m.checkAttachments()
+	if err != nil {
+		return err
+	}
+
+	// Attachments are in a multipart/mixed entity, next to the multipart/alternative one
+	mw := multipart.NewWriter(w)
+	subtype := "alternative"
+	if len(m.Attachments) > 0 {
+		subtype = "mixed"
+	}
+	headers = append(headers,
+		header{"MIME-Version", "1.0"},
+		header{"Content-Type", mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": mw.Boundary()})},
+	)
+	err = writeHeaders(w, headers)
+	if err != nil {
+		return err
+	}
+
+	if len(m.Attachments) == 0 {
+		err = m.writeAlternative(mw)
+		if err != nil {
+			return err
+		}
+		return mw.Close()
+	}
+	aw, err := createMultipart(mw, "alternative", nil)
+	if err != nil {
+		return err
+	}
+	err = m.writeAlternative(aw)
+	if err != nil {
+		return err
+	}
+	err = aw.Close()
+	if err != nil {
+		return err
+	}
+	for _, f := range m.Attachments {
+		err = writeFilePart(mw, f, false)
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// writeAlternative writes the plain text and HTML versions of the email
+// The HTML version is in a multipart/related entity with its inline images, if any.
+func (m *Message) writeAlternative(mw *multipart.Writer) error {
+	// Parts are ordered from the least to the most preferred version
+	err := writeTextPart(mw, "text/plain", m.Rendered.Text)
+	if err != nil {
+		return err
+	}
+	if len(m.Rendered.Images) == 0 {
+		return writeTextPart(mw, "text/html", m.Rendered.HTML)
+	}
+
+	rw, err := createMultipart(mw, "related", map[string]string{"type": "text/html"})
+	if err != nil {
+		return err
+	}
+	err = writeTextPart(rw, "text/html", m.Rendered.HTML)
+	if err != nil {
+		return err
+	}
+	for _, image := range m.Rendered.Images {
+		err = writeFilePart(rw, image, true)
+		if err != nil {
+			return err
+		}
+	}
+	return rw.Close()
+}
+
+// checkAttachments checks that attachments have a name and a valid content type, and do not exceed the maximum size
+func (m *Message) checkAttachments() error {
+	limit := m.MaxAttachmentSize
+	if limit == 0 {
+		limit = DefaultMaxAttachmentSize
+	}
+	var size int64
+	for _, f := range m.Attachments {
+		if f.Name == "" {
+			return errors.New("hermes: attachment has no name")
+		}
+		if f.ContentType != "" {
+			if _, err := f.parseContentType(); err != nil {
+				return err
+			}
+		}
+		size += int64(len(f.Content))
+	}
+	if size > limit {
+		return fmt.Errorf("hermes: attachments size (%d bytes) exceeds the maximum size of %d bytes", size, limit)
+	}
+	return nil
+}
+
+// headers returns the header fields of the message, encoded and validated
+func (m *Message) headers() ([]header, error) {
+	if _, err := m.Sender(); err != nil {
+		return nil, err
+	}
+	from, _ := mail.ParseAddress(m.From)
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return nil, errors.New("hermes: message has no recipient")
+	}
+
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	messageID := m.MessageID
+	if messageID == "" {
+		var err error
+		messageID, err = generateMessageID(from.Address)
+		if err != nil {
+			return nil, err
+		}
+	} else if err := checkMessageID(messageID); err != nil {
+		return nil, fmt.Errorf("hermes: invalid message ID %q: %v", messageID, err)
+	}
+
+	headers := []header{
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + messageID + ">"},
+		{"From", from.String()},
+	}
+	for _, h := range []struct {
+		key       string
+		addresses []string
+	}{
+		{"To", m.To},
+		{"Cc", m.Cc},
+		{"Reply-To", m.ReplyTo},
+	} {
+		if len(h.addresses) == 0 {
+			continue
+		}
+		addresses, err := parseAddressList(h.addresses)
+		if err != nil {
+			return nil, err
+		}
+		formatted := make([]string, len(addresses))
+		for i, a := range addresses {
+			formatted[i] = a.String()
+		}
+		headers = append(headers, header{h.key, strings.Join(formatted, ", ")})
+	}
+	// Bcc addresses are validated but never written
+	if _, err := parseAddressList(m.Bcc); err != nil {
+		return nil, err
+	}
+	headers = append(headers, header{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)})
+	return headers, nil
+}
+
+// parseAddressList parses addresses, each item possibly being a comma separated list of addresses
+func parseAddressList(list []string) ([]*mail.Address, error) {
+	var addresses []*mail.Address
+	for _, item := range list {
+		parsed, err := mail.ParseAddressList(item)
+		if err != nil {
+			return nil, fmt.Errorf("hermes: invalid address %q: %v", item, err)
+		}
+		addresses = append(addresses, parsed...)
+	}
+	return addresses, nil
+}
+
+// checkMessageID returns an error when id is not a message identifier without angle brackets, e.g. 1234@example.com
+// Only printable ASCII characters are allowed, so that the identifier can't add other header fields.
+func checkMessageID(id string) error {
+	for _, c := range id {
+		if c <= ' ' || c > '~' || c == '<' || c == '>' {
+			return fmt.Errorf("contains the invalid character %q", c)
+		}
+	}
+	if i := strings.Index(id, "@"); i <= 0 || i == len(id)-1 || strings.Count(id, "@") > 1 {
+		return errors.New("must have the form left@right")
+	}
+	return nil
+}
+
+// generateMessageID returns a random message identifier on the domain of the given address
+func generateMessageID(address string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	domain := "localhost"
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		domain = address[i+1:]
+	}
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(b), domain), nil
+}
+
+// writeHeaders writes header fields followed by the empty line separating them from the body
+func writeHeaders(w io.Writer, headers []header) error {
+	for _, h := range headers {
+		_, err := io.WriteString(w, foldHeader(h.key+": "+h.value)+"\r\n")
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// foldHeader folds a header line on whitespaces so that lines do not exceed the recommended length
+// Words longer than the recommended length are kept on their own line
+func foldHeader(line string) string {
+	if len(line) <= maxHeaderLineLength {
+		return line
+	}
+	var b strings.Builder
+	lineLength := 0
+	for i, word := range strings.Split(line, " ") {
+		if i > 0 {
+			if lineLength+1+len(word) > maxHeaderLineLength {
+				b.WriteString("\r\n")
+				lineLength = 0
+			}
+			b.WriteString(" ")
+			lineLength++
+		}
+		b.WriteString(word)
+		lineLength += len(word)
+	}
+	return b.String()
+}
+
+// foldHeaderValue folds the value of a header field written by a multipart writer
+func foldHeaderValue(key string, value string) string {
+	return strings.TrimPrefix(foldHeader(key + ": " + value)[len(key)+1:], " ")
+}
+
+// writeTextPart writes a text part encoded in quoted-printable
+func writeTextPart(mw *multipart.Writer, contentType string, content string) error {
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"charset": "UTF-8"})},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(pw)
+	_, err = io.WriteString(qp, content)
+	if err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// createMultipart creates a nested multipart entity of the given subtype as a part of mw
+func createMultipart(mw *multipart.Writer, subtype string, params map[string]string) (*multipart.Writer, error) {
+	boundary := multipart.NewWriter(nil).Boundary()
+	contentParams := map[string]string{"boundary": boundary}
+	for k, v := range params {
+		contentParams[k] = v
+	}
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {foldHeaderValue("Content-Type", mime.FormatMediaType("multipart/"+subtype, contentParams))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	nested := multipart.NewWriter(pw)
+	err = nested.SetBoundary(boundary)
+	if err != nil {
+		return nil, err
+	}
+	return nested, nil
+}
+
+// writeFilePart writes a file encoded in base64, either as an inline image or as an attachment
+func writeFilePart(mw *multipart.Writer, f *File, inline bool) error {
+	h := textproto.MIMEHeader{
+		"Content-Type":              {f.MediaType()},
+		"Content-Transfer-Encoding": {"base64"},
+	}
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+		h.Set("Content-ID", "<"+f.ContentID()+">")
+	}
+	h.Set("Content-Disposition", foldHeaderValue("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": f.Name})))
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(f.Content)
+	for len(encoded) > 0 {
+		n := maxBase64LineLength
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		_, err = io.WriteString(pw, encoded[:n]+"\r\n")
+		if err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// countingWriter counts bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
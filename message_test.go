@@ -0,0 +1,245 @@
+package hermes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestMessage(t *testing.T) *Message {
+	h, email := (&SimpleExample{new(Default)}).getExample()
+	rendered, err := h.Generate(email)
+	assert.Nil(t, err)
+	return &Message{
+		From:      "Hermes <hermes@example.com>",
+		To:        []string{"Jon Snow <jon@example.com>", "arya@example.com, Sansa Stark <sansa@example.com>"},
+		Cc:        []string{"Bran Stark <bran@example.com>"},
+		Bcc:       []string{"Hodor <hodor@example.com>"},
+		ReplyTo:   []string{"Support <support@example.com>"},
+		Subject:   "Bienvenue à Winterfell, l'hiver arrive et il est temps de confirmer votre compte Hermes ❄️",
+		Date:      time.Date(2017, time.August, 1, 10, 0, 0, 0, time.UTC),
+		MessageID: "welcome@example.com",
+		Rendered:  rendered,
+	}
+}
+
+func TestMessage_WriteTo(t *testing.T) {
+	m := getTestMessage(t)
+	var b bytes.Buffer
+	n, err := m.WriteTo(&b)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(b.Len()), n, "Should return the number of written bytes")
+
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		assert.True(t, len(line) <= 78, "Line should not exceed 78 characters: %q", line)
+	}
+	assert.NotContains(t, b.String(), "hodor", "Bcc should not be written in headers")
+
+	parsed, err := mail.ReadMessage(&b)
+	assert.Nil(t, err)
+
+	// Headers
+	assert.Equal(t, "1.0", parsed.Header.Get("MIME-Version"))
+	assert.Equal(t, "<welcome@example.com>", parsed.Header.Get("Message-ID"))
+	date, err := parsed.Header.Date()
+	assert.Nil(t, err)
+	assert.True(t, m.Date.Equal(date))
+	from, err := mail.ParseAddress(parsed.Header.Get("From"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hermes@example.com", from.Address)
+	to, err := parsed.Header.AddressList("To")
+	assert.Nil(t, err)
+	assert.Len(t, to, 3)
+	assert.Equal(t, "Sansa Stark", to[2].Name)
+	cc, err := parsed.Header.AddressList("Cc")
+	assert.Nil(t, err)
+	assert.Equal(t, "bran@example.com", cc[0].Address)
+	replyTo, err := parsed.Header.AddressList("Reply-To")
+	assert.Nil(t, err)
+	assert.Equal(t, "support@example.com", replyTo[0].Address)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.Nil(t, err)
+	assert.Equal(t, m.Subject, subject, "Subject should be decoded to its original UTF-8 value")
+
+	// Body
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	assert.Nil(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, expected := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", m.Rendered.Text},
+		{"text/html; charset=UTF-8", m.Rendered.HTML},
+	} {
+		// multipart.Reader transparently decodes quoted-printable parts
+		part, err := mr.NextPart()
+		assert.Nil(t, err)
+		assert.Equal(t, expected.contentType, part.Header.Get("Content-Type"))
+		content, err := ioutil.ReadAll(part)
+		assert.Nil(t, err)
+		assert.Equal(t, normalizeLineBreaks(expected.content), string(content))
+	}
+	_, err = mr.NextPart()
+	assert.NotNil(t, err, "Should only have two parts")
+}
+
+func TestMessage_Recipients(t *testing.T) {
+	m := getTestMessage(t)
+	recipients, err := m.Recipients()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"jon@example.com", "arya@example.com", "sansa@example.com", "bran@example.com", "hodor@example.com"}, recipients)
+}
+
+func TestMessage_WriteToInvalid(t *testing.T) {
+	m := getTestMessage(t)
+	m.From = ""
+	_, err := m.WriteTo(ioutil.Discard)
+	assert.NotNil(t, err, "Should not write a message without sender")
+
+	m = getTestMessage(t)
+	m.To, m.Cc, m.Bcc = nil, nil, nil
+	_, err = m.WriteTo(ioutil.Discard)
+	assert.NotNil(t, err, "Should not write a message without recipient")
+
+	m = getTestMessage(t)
+	m.Cc = []string{"not an address"}
+	_, err = m.WriteTo(ioutil.Discard)
+	assert.NotNil(t, err, "Should not write a message with an invalid address")
+
+	for _, id := range []string{"x@y>\r\nBcc: victim@example.com\r\nX-Injected: <z", "1234 @example.com", "1234", "@example.com", "a@b@c"} {
+		m = getTestMessage(t)
+		m.MessageID = id
+		_, err = m.WriteTo(ioutil.Discard)
+		assert.NotNil(t, err, "Should not write a message with the invalid message ID %q", id)
+	}
+}
+
+func TestFoldHeader(t *testing.T) {
+	assert.Equal(t, "Subject: short", foldHeader("Subject: short"))
+	folded := foldHeader("Subject: " + strings.Repeat("word ", 30))
+	for _, line := range strings.Split(folded, "\r\n") {
+		assert.True(t, len(line) <= 78, "Line should not exceed 78 characters: %q", line)
+	}
+	assert.Equal(t, "Subject: "+strings.Repeat("word ", 30), strings.Replace(folded, "\r\n", "", -1), "Unfolding should give the original header")
+}
+
+func normalizeLineBreaks(s string) string {
+	var b bytes.Buffer
+	w := quotedprintable.NewWriter(&b)
+	w.Write([]byte(s))
+	w.Close()
+	decoded, _ := ioutil.ReadAll(quotedprintable.NewReader(&b))
+	return string(decoded)
+}
+
+func TestMessage_WriteToWithImagesAndAttachments(t *testing.T) {
+	logo, err := ReadFile("examples/gopher.png")
+	assert.Nil(t, err)
+	h, email := (&SimpleExample{new(Default)}).getExample()
+	h.Product.LogoFile = logo
+	rendered, err := h.Generate(email)
+	assert.Nil(t, err)
+
+	m := getTestMessage(t)
+	m.Rendered = rendered
+	m.Attachments = []*File{
+		{Name: "invoice.pdf", Content: []byte("%PDF-1.4 invoice")},
+		{Name: "notes.txt", ContentType: "text/plain; charset=UTF-8", Content: []byte("Winter is coming")},
+	}
+	var b bytes.Buffer
+	_, err = m.WriteTo(&b)
+	assert.Nil(t, err)
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		assert.True(t, len(line) <= 78, "Line should not exceed 78 characters: %q", line)
+	}
+
+	parsed, err := mail.ReadMessage(&b)
+	assert.Nil(t, err)
+
+	// multipart/mixed > [multipart/alternative, attachments...]
+	mixed := readMultipart(t, parsed.Header.Get("Content-Type"), parsed.Body, "multipart/mixed")
+	alternativePart, err := mixed.NextPart()
+	assert.Nil(t, err)
+
+	// multipart/alternative > [text/plain, multipart/related]
+	alternative := readMultipart(t, alternativePart.Header.Get("Content-Type"), alternativePart, "multipart/alternative")
+	text, err := alternative.NextPart()
+	assert.Nil(t, err)
+	assert.Equal(t, "text/plain; charset=UTF-8", text.Header.Get("Content-Type"))
+	relatedPart, err := alternative.NextPart()
+	assert.Nil(t, err)
+
+	// multipart/related > [text/html, image]
+	related := readMultipart(t, relatedPart.Header.Get("Content-Type"), relatedPart, "multipart/related")
+	html, err := related.NextPart()
+	assert.Nil(t, err)
+	content, err := ioutil.ReadAll(html)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `src="cid:gopher.png"`, "Logo should be referenced with its Content-ID")
+	image, err := related.NextPart()
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", image.Header.Get("Content-Type"))
+	assert.Equal(t, "<gopher.png>", image.Header.Get("Content-ID"))
+	assert.Equal(t, "inline", strings.SplitN(image.Header.Get("Content-Disposition"), ";", 2)[0])
+	assert.Equal(t, logo.Content, readBase64(t, image))
+
+	// Attachments
+	for _, expected := range m.Attachments {
+		attachment, err := mixed.NextPart()
+		assert.Nil(t, err)
+		assert.Equal(t, expected.Name, attachment.FileName())
+		assert.Equal(t, expected.MediaType(), attachment.Header.Get("Content-Type"))
+		assert.Equal(t, expected.Content, readBase64(t, attachment))
+	}
+	_, err = mixed.NextPart()
+	assert.NotNil(t, err, "Should not have more parts than attachments")
+}
+
+func TestMessage_WriteToAttachmentTooLarge(t *testing.T) {
+	m := getTestMessage(t)
+	m.Attachments = []*File{
+		{Name: "big.bin", Content: make([]byte, 600)},
+		{Name: "other.bin", Content: make([]byte, 600)},
+	}
+	m.MaxAttachmentSize = 1000
+	_, err := m.WriteTo(ioutil.Discard)
+	assert.NotNil(t, err, "Should not write a message with attachments exceeding the maximum size")
+
+	m.MaxAttachmentSize = 1200
+	_, err = m.WriteTo(ioutil.Discard)
+	assert.Nil(t, err)
+
+	m.Attachments = []*File{{Content: []byte("no name")}}
+	_, err = m.WriteTo(ioutil.Discard)
+	assert.NotNil(t, err, "Should not write a message with an attachment without name")
+
+	m.Attachments = []*File{{Name: "notes.txt", ContentType: "text/plain\r\nX-Injected: true", Content: []byte("Winter is coming")}}
+	_, err = m.WriteTo(ioutil.Discard)
+	assert.NotNil(t, err, "Should not write a message with an attachment with an invalid content type")
+}
+
+func readMultipart(t *testing.T, contentType string, r io.Reader, expectedMediaType string) *multipart.Reader {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedMediaType, mediaType)
+	return multipart.NewReader(r, params["boundary"])
+}
+
+func readBase64(t *testing.T, part *multipart.Part) []byte {
+	assert.Equal(t, "base64", part.Header.Get("Content-Transfer-Encoding"))
+	content, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+	assert.Nil(t, err)
+	return content
+}
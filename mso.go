@@ -0,0 +1,48 @@
+package hermes
+
+// msoPartials are partial templates available to all themes, rendering actions for Outlook on Windows
+// Outlook renders emails with Word, which ignores the padding and the border radius of links:
+// buttons are drawn with VML instead, in conditional comments only read by Outlook.
+var msoPartials = map[string]string{
+	// mso-action renders a button or an invite code, e.g.
+	// {{ template "mso-action" (dict "Hermes" $.Hermes "Action" $action "Width" 200 "ArcSize" "10%") }}
+	// Width is the width of the button in pixels and ArcSize its border radius, as a percentage of its height.
+	"mso-action": `{{ $options := .Hermes.ThemeOptions }}
+{{- safe "<!--[if mso]>" }}
+{{- if .Action.Button.Text }}
+  {{- $color := .Action.Button.Color | default $options.PrimaryColor | default "#3869D4" }}
+  {{- $textColor := .Action.Button.TextColor | default $options.ButtonTextColor | default "#FFFFFF" }}
+  <div style="margin: 30px auto;v-text-anchor:middle;text-align:center">
+    <v:roundrect xmlns:v="urn:schemas-microsoft-com:vml"
+      xmlns:w="urn:schemas-microsoft-com:office:word"
+      href="{{ .Action.Button.Link | url }}"
+      style="height:{{ $options.ButtonHeight | default 45 }}px;v-text-anchor:middle;width:{{ .Width | default 200 }}px;background-color:{{ $color | css }};"
+      arcsize="{{ .ArcSize | default "0%" }}"
+      strokecolor="{{ $color }}" fillcolor="{{ $color }}"
+      >
+      <w:anchorlock/>
+      <center style="color: {{ $textColor | css }};font-size: 15px;text-align: center;font-family:sans-serif;font-weight:bold;">
+        {{ .Action.Button.Text }}
+      </center>
+    </v:roundrect>
+  </div>
+{{- end }}
+{{- if .Action.InviteCode }}
+  <div style="margin-top:30px;margin-bottom:30px">
+    <table class="body-action" align="center" width="100%" cellpadding="0" cellspacing="0">
+      <tr>
+        <td align="center">
+          <table align="center" cellpadding="0" cellspacing="0" style="padding:0;text-align:center">
+            <tr>
+              <td style="display:inline-block;border-radius:{{ $options.BorderRadius }}px;font-family:{{ $options.MonospaceFontFamily | default "Consolas, monaco, monospace" | css }};font-size:28px;text-align:center;letter-spacing:8px;color:#555;background-color:{{ $options.MutedBackgroundColor | default "#F2F4F6" | css }};padding:20px">
+                {{ .Action.InviteCode }}
+              </td>
+            </tr>
+          </table>
+        </td>
+      </tr>
+    </table>
+  </div>
+{{- end }}
+{{ safe "<![endif]-->" }}`,
+}
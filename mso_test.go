@@ -0,0 +1,51 @@
+package hermes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundledThemesMSOActions(t *testing.T) {
+	email := Email{Body{Actions: []Action{
+		{Button: Button{Text: "Confirm", Link: "https://hermes-example.com/confirm", Color: "#22BC66", TextColor: "#000000"}},
+		{InviteCode: "123456"},
+	}}}
+	for _, theme := range Themes() {
+		h := Hermes{Theme: theme}
+		res, err := h.GenerateHTML(email)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, strings.Count(res, "<!--[if mso]>"), "Each action of %s should have an Outlook version", theme.Name())
+		assert.Contains(t, res, `href="https://hermes-example.com/confirm"`)
+		assert.Regexp(t, `<v:roundrect [^>]*style="height:45px;v-text-anchor:middle;width:\d+px;background-color:#22BC66;"`, res)
+		assert.Contains(t, res, `strokecolor="#22BC66" fillcolor="#22BC66"`)
+		assert.Contains(t, res, `<center style="color: #000000;`)
+		assert.Regexp(t, `<td style="display:inline-block;[^"]*">\s*123456\s*</td>`, res, "Invite codes of %s should have an Outlook version", theme.Name())
+	}
+}
+
+func TestMSOActionInCustomTheme(t *testing.T) {
+	theme := &fieldsTheme{Theme: new(Default), HTML: `{{ range .Email.Body.Actions }}{{ template "mso-action" (dict "Hermes" $.Hermes "Action" .) }}{{ end }}`}
+	h := Hermes{Theme: theme}
+	res, err := h.GenerateHTML(Email{Body{Actions: []Action{{Button: Button{Text: "Confirm", Link: "https://hermes-example.com/confirm"}}}}})
+	assert.Nil(t, err)
+	assert.Contains(t, res, "<v:roundrect", "Outlook buttons should be available to all themes")
+	assert.Contains(t, res, "style=\"height:45px;v-text-anchor:middle;width:200px;background-color:#3869D4;\"", "Themes without options should get default sizes and colors")
+	assert.Contains(t, res, `arcsize="0%"`)
+	assert.Contains(t, res, `<center style="color: #FFFFFF;`)
+}
+
+func TestBundledThemesMSOActionColors(t *testing.T) {
+	for _, theme := range Themes() {
+		h := Hermes{Theme: theme}
+		_, err := h.GenerateHTML(Email{Body{Actions: []Action{{Button: Button{Text: "Confirm", Link: "https://hermes-example.com/confirm", Color: "red;background-image:url(https://tracker.example/p)"}}}}})
+		assert.Equal(t, ValidationErrors{{Field: "body.actions[0].button.color", Message: "is not a valid color"}}, err, "Invalid colors of %s should be rejected", theme.Name())
+
+		res, err := h.GenerateHTML(Email{Body{Actions: []Action{{Button: Button{Text: "Confirm", Link: "https://hermes-example.com/confirm", Color: "rgb(34, 188, 102)"}}}}})
+		assert.Nil(t, err)
+		assert.Contains(t, res, "v-text-anchor:middle;width:", theme.Name())
+		assert.Regexp(t, `<v:roundrect [^>]*style="[^"]*background-color:#22BC66;"`, res, "Valid colors of %s should be written in hex", theme.Name())
+		assert.NotContains(t, res, "ZgotmplZ")
+	}
+}
@@ -0,0 +1,137 @@
+package hermes
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Renderer is a compiled version of the hermes email generator
+// Default values are applied and theme templates are parsed only once, at creation.
+// A Renderer never mutates its state, so it is safe for concurrent use by multiple goroutines.
+type Renderer struct {
+	hermes    Hermes
+	html      *template.Template
+	plainText *template.Template
+}
+
+// NewRenderer creates a Renderer from the given hermes configuration
+// The configuration is copied: later changes on h are not seen by the Renderer
+func NewRenderer(h Hermes) (*Renderer, error) {
+	err := setDefaultHermesValues(&h)
+	if err != nil {
+		return nil, err
+	}
+	html, err := parseThemeTemplate(h.Theme, true, h.Sandbox)
+	if err != nil {
+		return nil, err
+	}
+	plainText, err := parseThemeTemplate(h.Theme, false, h.Sandbox)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{
+		hermes:    h,
+		html:      html,
+		plainText: plainText,
+	}, nil
+}
+
+// Rendered is an email generated in both HTML and plain text versions
+type Rendered struct {
+	HTML          string           // HTML version, for modern email clients
+	Text          string           // Plain text version, for old email clients
+	Theme         string           // Name of the theme used to generate the email
+	TextDirection TextDirection    // Text direction of the HTML version
+	HTMLSize      int              // Size of the HTML version, in bytes
+	TextSize      int              // Size of the plain text version, in bytes
+	Images        []*File          // Images to embed in the message, referenced in the HTML version with their `cid:` URL
+	Warnings      ValidationErrors // Issues found in the email, e.g. unreadable colors when Hermes.ContrastPolicy is ContrastWarn, only reported here
+}
+
+// Hermes returns the configuration used by the renderer, with its default values
+func (r *Renderer) Hermes() Hermes {
+	return r.hermes
+}
+
+// GenerateHTML generates the email body from data to an HTML Reader
+// This is for modern email clients
+func (r *Renderer) GenerateHTML(email Email) (string, error) {
+	var b strings.Builder
+	err := r.GenerateHTMLTo(&b, email)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// GenerateHTMLTo generates the email body from data and writes the HTML to w
+// This is for modern email clients
+func (r *Renderer) GenerateHTMLTo(w io.Writer, email Email) error {
+	data, err := templateData(r.hermes, email)
+	if err != nil {
+		return err
+	}
+	return executeHTML(context.Background(), w, r.html, data)
+}
+
+// GeneratePlainText generates the email body from data
+// This is for old email clients
+func (r *Renderer) GeneratePlainText(email Email) (string, error) {
+	var b strings.Builder
+	err := r.GeneratePlainTextTo(&b, email)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// GeneratePlainTextTo generates the email body from data and writes the plain text to w
+// This is for old email clients
+func (r *Renderer) GeneratePlainTextTo(w io.Writer, email Email) error {
+	data, err := templateData(r.hermes, email)
+	if err != nil {
+		return err
+	}
+	return executePlainText(context.Background(), w, r.plainText, data)
+}
+
+// Generate generates both the HTML and the plain text versions of the email
+// Default values are applied once and shared by both versions
+func (r *Renderer) Generate(email Email) (Rendered, error) {
+	return r.GenerateContext(context.Background(), email)
+}
+
+// GenerateContext generates both the HTML and the plain text versions of the email, until ctx is done
+func (r *Renderer) GenerateContext(ctx context.Context, email Email) (Rendered, error) {
+	ctx, cancel := r.hermes.Sandbox.context(ctx)
+	defer cancel()
+	data, err := templateData(r.hermes, email)
+	if err != nil {
+		return Rendered{}, err
+	}
+	images, err := inlineImages(r.hermes, email)
+	if err != nil {
+		return Rendered{}, err
+	}
+	var html, text strings.Builder
+	err = executeHTML(ctx, &html, r.html, data)
+	if err != nil {
+		return Rendered{}, err
+	}
+	err = executePlainText(ctx, &text, r.plainText, data)
+	if err != nil {
+		return Rendered{}, err
+	}
+	return Rendered{
+		HTML:          html.String(),
+		Text:          text.String(),
+		Theme:         r.hermes.Theme.Name(),
+		TextDirection: r.hermes.TextDirection,
+		HTMLSize:      html.Len(),
+		TextSize:      text.Len(),
+		Images:        images,
+		Warnings:      data.warnings,
+	}, nil
+}
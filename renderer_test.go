@@ -0,0 +1,237 @@
+package hermes
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRendererSimple(t *testing.T) {
+	for _, theme := range testedThemes {
+		checkRendererExample(t, &SimpleExample{theme})
+	}
+}
+
+func TestRendererWithFreeMarkdownContent(t *testing.T) {
+	for _, theme := range testedThemes {
+		checkRendererExample(t, &WithFreeMarkdownContent{theme})
+	}
+}
+
+func TestRendererWithInviteCode(t *testing.T) {
+	for _, theme := range testedThemes {
+		checkRendererExample(t, &WithInviteCode{theme})
+	}
+}
+
+func checkRendererExample(t *testing.T, ex Example) {
+	// Given a renderer compiled from an example
+	h, email := ex.getExample()
+	r, err := NewRenderer(h)
+	assert.Nil(t, err)
+
+	// When generating HTML template
+	res, err := r.GenerateHTML(email)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res)
+
+	// Then asserting HTML is OK
+	ex.assertHTMLContent(t, res)
+
+	// When generating plain text template
+	res, err = r.GeneratePlainText(email)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res)
+
+	// Then asserting plain text is OK
+	ex.assertPlainTextContent(t, res)
+}
+
+func TestNewRenderer_DoesNotMutateConfiguration(t *testing.T) {
+	h := Hermes{
+		TextDirection: "not-existing",
+	}
+	r, err := NewRenderer(h)
+	assert.Nil(t, err)
+
+	assert.Nil(t, h.Theme, "Given configuration should be left untouched")
+	assert.Equal(t, TextDirection("not-existing"), h.TextDirection, "Given configuration should be left untouched")
+	assert.Empty(t, h.Product.Name, "Given configuration should be left untouched")
+
+	assert.Equal(t, new(Default), r.Hermes().Theme)
+	assert.Equal(t, TDLeftToRight, r.Hermes().TextDirection)
+	assert.Equal(t, "Hermes", r.Hermes().Product.Name)
+}
+
+func TestNewRenderer_InvalidTemplate(t *testing.T) {
+	_, err := NewRenderer(Hermes{Theme: &brokenTheme{}})
+	assert.NotNil(t, err)
+}
+
+func TestRenderer_ConcurrentUse(t *testing.T) {
+	for _, theme := range testedThemes {
+		ex := &SimpleExample{theme}
+		h, email := ex.getExample()
+		h.DisableCSSInlining = false
+		r, err := NewRenderer(h)
+		assert.Nil(t, err)
+
+		expectedHTML, err := r.GenerateHTML(email)
+		assert.Nil(t, err)
+		expectedText, err := r.GeneratePlainText(email)
+		assert.Nil(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				html, err := r.GenerateHTML(email)
+				assert.Nil(t, err)
+				assert.Equal(t, expectedHTML, html)
+				text, err := r.GeneratePlainText(email)
+				assert.Nil(t, err)
+				assert.Equal(t, expectedText, text)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestRenderer_GenerateTo(t *testing.T) {
+	for _, theme := range testedThemes {
+		for _, inlining := range []bool{true, false} {
+			h, email := (&SimpleExample{theme}).getExample()
+			h.DisableCSSInlining = !inlining
+			r, err := NewRenderer(h)
+			assert.Nil(t, err)
+
+			var html bytes.Buffer
+			err = r.GenerateHTMLTo(&html, email)
+			assert.Nil(t, err)
+			expected, err := r.GenerateHTML(email)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, html.String(), "Streamed HTML should be the same as generated HTML")
+
+			var text bytes.Buffer
+			err = r.GeneratePlainTextTo(&text, email)
+			assert.Nil(t, err)
+			expected, err = r.GeneratePlainText(email)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, text.String(), "Streamed plain text should be the same as generated plain text")
+		}
+	}
+}
+
+func TestRenderer_Generate(t *testing.T) {
+	for _, theme := range testedThemes {
+		h, email := (&SimpleExample{theme}).getExample()
+		h.TextDirection = TDRightToLeft
+		r, err := NewRenderer(h)
+		assert.Nil(t, err)
+
+		rendered, err := r.Generate(email)
+		assert.Nil(t, err)
+
+		html, err := r.GenerateHTML(email)
+		assert.Nil(t, err)
+		text, err := r.GeneratePlainText(email)
+		assert.Nil(t, err)
+		assert.Equal(t, html, rendered.HTML, "HTML should be the same as generated alone")
+		assert.Equal(t, text, rendered.Text, "Plain text should be the same as generated alone")
+		assert.Equal(t, theme.Name(), rendered.Theme)
+		assert.Equal(t, TDRightToLeft, rendered.TextDirection)
+		assert.Equal(t, len(html), rendered.HTMLSize)
+		assert.Equal(t, len(text), rendered.TextSize)
+	}
+}
+
+func TestRenderer_GenerateToExecutionError(t *testing.T) {
+	for _, inlining := range []bool{true, false} {
+		r, err := NewRenderer(Hermes{Theme: &failingTheme{}, DisableCSSInlining: !inlining})
+		assert.Nil(t, err)
+
+		err = r.GenerateHTMLTo(new(bytes.Buffer), Email{})
+		assert.NotNil(t, err, "Execution error should be returned when generating HTML")
+		err = r.GeneratePlainTextTo(new(bytes.Buffer), Email{})
+		assert.NotNil(t, err, "Execution error should be returned when generating plain text")
+		_, err = r.Generate(Email{})
+		assert.NotNil(t, err, "Execution error should be returned when generating both versions")
+	}
+}
+
+func TestRenderer_GenerateToWriterError(t *testing.T) {
+	r, err := NewRenderer(Hermes{})
+	assert.Nil(t, err)
+
+	err = r.GenerateHTMLTo(failingWriter{}, Email{})
+	assert.Equal(t, errWrite, err)
+	err = r.GeneratePlainTextTo(failingWriter{}, Email{})
+	assert.Equal(t, errWrite, err)
+}
+
+func BenchmarkRenderer_GenerateHTML(b *testing.B) {
+	h, email := (&SimpleExample{new(Default)}).getExample()
+	r, err := NewRenderer(h)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.GenerateHTML(email); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHermes_GenerateHTML(b *testing.B) {
+	h, email := (&SimpleExample{new(Default)}).getExample()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := h.GenerateHTML(email); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// brokenTheme is a theme whose templates cannot be parsed
+type brokenTheme struct{}
+
+func (bt *brokenTheme) Name() string {
+	return "broken"
+}
+
+func (bt *brokenTheme) HTMLTemplate() string {
+	return "{{ if }}"
+}
+
+func (bt *brokenTheme) PlainTextTemplate() string {
+	return "{{ end }}"
+}
+
+// failingTheme is a theme whose templates can be parsed but fail at execution
+type failingTheme struct{}
+
+func (ft *failingTheme) Name() string {
+	return "failing"
+}
+
+func (ft *failingTheme) HTMLTemplate() string {
+	return "<html><body><p>{{ index .Email.Body.Intros 5 }}</p></body></html>"
+}
+
+func (ft *failingTheme) PlainTextTemplate() string {
+	return "<p>{{ index .Email.Body.Outros 5 }}</p>"
+}
+
+var errWrite = errors.New("write error")
+
+// failingWriter is a writer always returning an error
+type failingWriter struct{}
+
+func (fw failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
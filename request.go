@@ -0,0 +1,181 @@
+package hermes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/imdario/mergo"
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats of a RenderRequest
+const (
+	FormatHTML      = "html" // HTML version of the email (default)
+	FormatPlainText = "text" // Plain text version of the email
+	FormatMessage   = "eml"  // Complete MIME message, as an .eml file
+)
+
+// RenderRequest describes an email to render without writing Go code
+// It is the JSON body of Server render requests and the content of hermes command files.
+type RenderRequest struct {
+	Theme         string          `json:"theme"`         // Name of the theme (default to the configured one)
+	TextDirection TextDirection   `json:"textDirection"` // Text direction (default to the configured one)
+	Product       *Product        `json:"product"`       // Product overriding non empty fields of the configured one
+	ThemeOptions  *ThemeOptions   `json:"themeOptions"`  // Theme options overriding non zero fields of the configured ones
+	Email         Email           `json:"email"`         // Email to render
+	Format        string          `json:"format"`        // One of html, text or eml (default to html)
+	Message       *MessageRequest `json:"message"`       // Headers and attachments, required by the eml format
+}
+
+// MessageRequest is the part of a RenderRequest describing the message of the eml format
+type MessageRequest struct {
+	From        string   `json:"from"`
+	To          []string `json:"to"`
+	Cc          []string `json:"cc"`
+	ReplyTo     []string `json:"replyTo"`
+	Subject     string   `json:"subject"`
+	MessageID   string   `json:"messageId"`
+	Attachments []*File  `json:"attachments"`
+}
+
+// ReadRenderRequest reads a RenderRequest written in JSON or YAML
+// Both use the same field names, e.g. `textDirection` or `email.body.freeMarkdown`.
+func ReadRenderRequest(r io.Reader) (RenderRequest, error) {
+	var req RenderRequest
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return req, err
+	}
+	// JSON being valid YAML, the document is converted to JSON to share the JSON field names and encodings
+	var document interface{}
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return req, fmt.Errorf("hermes: invalid document: %v", err)
+	}
+	document, err = jsonCompatible(document)
+	if err != nil {
+		return req, err
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return req, fmt.Errorf("hermes: invalid document: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&req)
+	if err != nil {
+		return req, fmt.Errorf("hermes: invalid document: %v", err)
+	}
+	return req, nil
+}
+
+// jsonCompatible converts YAML mappings, which may have any type of key, to JSON objects
+func jsonCompatible(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("hermes: invalid document: key %v is not a string", key)
+			}
+			converted, err := jsonCompatible(value)
+			if err != nil {
+				return nil, err
+			}
+			object[k] = converted
+		}
+		return object, nil
+	case []interface{}:
+		for i, value := range v {
+			converted, err := jsonCompatible(value)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+	}
+	return v, nil
+}
+
+// Configure returns the configuration rendering the request, overriding h with the options of the request
+// The theme is chosen among themes. Returned error is a ValidationErrors listing all the invalid fields, if any.
+func (req RenderRequest) Configure(h Hermes, themes []Theme) (Hermes, error) {
+	var errs ValidationErrors
+	if req.Theme != "" {
+		t, err := findTheme(themes, req.Theme)
+		if err != nil {
+			errs.add("theme", "is not one of the available themes")
+		}
+		h.Theme = t
+	}
+	switch req.TextDirection {
+	case "":
+	case TDLeftToRight, TDRightToLeft:
+		h.TextDirection = req.TextDirection
+	default:
+		errs.add("textDirection", "must be %s or %s", TDLeftToRight, TDRightToLeft)
+	}
+	if req.Product != nil {
+		product := *req.Product
+		if err := mergo.Merge(&product, h.Product); err != nil {
+			errs.add("product", "%v", err)
+		}
+		h.Product = product
+	}
+	if req.ThemeOptions != nil {
+		options := *req.ThemeOptions
+		options.validate(&errs, "themeOptions")
+		if err := mergo.Merge(&options, h.ThemeOptions); err != nil {
+			errs.add("themeOptions", "%v", err)
+		}
+		h.ThemeOptions = options
+	}
+	switch req.Format {
+	case "", FormatHTML, FormatPlainText:
+	case FormatMessage:
+		if req.Message == nil {
+			errs.add("message", "is required by the %s format", FormatMessage)
+			break
+		}
+		if req.Message.From == "" {
+			errs.add("message.from", "is required")
+		}
+		if len(req.Message.To)+len(req.Message.Cc) == 0 {
+			errs.add("message.to", "is required")
+		}
+		if req.Message.MessageID != "" {
+			if err := checkMessageID(req.Message.MessageID); err != nil {
+				errs.add("message.messageId", "%v", err)
+			}
+		}
+	default:
+		errs.add("format", "must be %s, %s or %s", FormatHTML, FormatPlainText, FormatMessage)
+	}
+	if err := req.Email.Validate(); err != nil {
+		for _, e := range err.(ValidationErrors) {
+			errs.add("email."+e.Field, "%s", e.Message)
+		}
+	}
+	if len(errs) > 0 {
+		return h, errs
+	}
+	return h, nil
+}
+
+// Message returns the message of the eml format, made of the rendered email
+func (m *MessageRequest) Message(rendered Rendered) *Message {
+	return &Message{
+		From:        m.From,
+		To:          m.To,
+		Cc:          m.Cc,
+		ReplyTo:     m.ReplyTo,
+		Subject:     m.Subject,
+		MessageID:   m.MessageID,
+		Rendered:    rendered,
+		Attachments: m.Attachments,
+	}
+}
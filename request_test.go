@@ -0,0 +1,101 @@
+package hermes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRenderRequest(t *testing.T) {
+	fromJSON, err := ReadRenderRequest(strings.NewReader(`{
+		"theme": "flat",
+		"textDirection": "rtl",
+		"product": {"name": "Hermes", "link": "https://example-hermes.com/"},
+		"email": {
+			"body": {
+				"name": "Jon Snow",
+				"intros": ["Welcome to Hermes!"],
+				"dictionary": [{"key": "Firstname", "value": "Jon"}],
+				"freeMarkdown": "**Winter** is coming",
+				"images": [{"name": "chart.png", "content": "iVBORw0KGgo="}]
+			}
+		},
+		"format": "eml",
+		"message": {"from": "hermes@example.com", "to": ["jon@example.com"], "replyTo": ["support@example.com"]}
+	}`))
+	assert.Nil(t, err)
+	fromYAML, err := ReadRenderRequest(strings.NewReader(`
+theme: flat
+textDirection: rtl
+product:
+  name: Hermes
+  link: https://example-hermes.com/
+email:
+  body:
+    name: Jon Snow
+    intros:
+      - Welcome to Hermes!
+    dictionary:
+      - key: Firstname
+        value: Jon
+    freeMarkdown: "**Winter** is coming"
+    images:
+      - name: chart.png
+        content: iVBORw0KGgo=
+format: eml
+message:
+  from: hermes@example.com
+  to: [jon@example.com]
+  replyTo: [support@example.com]
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, fromJSON, fromYAML, "JSON and YAML should be read the same way")
+
+	assert.Equal(t, "flat", fromYAML.Theme)
+	assert.Equal(t, TDRightToLeft, fromYAML.TextDirection)
+	assert.Equal(t, "Hermes", fromYAML.Product.Name)
+	assert.Equal(t, "Jon Snow", fromYAML.Email.Body.Name)
+	assert.Equal(t, []Entry{{Key: "Firstname", Value: "Jon"}}, fromYAML.Email.Body.Dictionary)
+	assert.Equal(t, Markdown("**Winter** is coming"), fromYAML.Email.Body.FreeMarkdown)
+	assert.Equal(t, []byte("\x89PNG\r\n\x1a\n"), fromYAML.Email.Body.Images[0].Content)
+	assert.Equal(t, []string{"support@example.com"}, fromYAML.Message.ReplyTo)
+}
+
+func TestReadRenderRequestInvalid(t *testing.T) {
+	for _, document := range []string{
+		`{"email": `,
+		`email: [`,
+		`unknown: true`,
+		`{1: true}`,
+		`email: {body: {intros: Welcome}}`,
+	} {
+		_, err := ReadRenderRequest(strings.NewReader(document))
+		assert.NotNil(t, err, "Document should be invalid: %s", document)
+	}
+}
+
+func TestRenderRequest_Configure(t *testing.T) {
+	base := Hermes{Theme: new(Default), Product: Product{Name: "Hermes", Link: "https://example-hermes.com/"}}
+	req := RenderRequest{
+		Theme:         "flat",
+		TextDirection: TDRightToLeft,
+		Product:       &Product{Name: "Overridden"},
+	}
+	h, err := req.Configure(base, Themes())
+	assert.Nil(t, err)
+	assert.Equal(t, "flat", h.Theme.Name())
+	assert.Equal(t, TDRightToLeft, h.TextDirection)
+	assert.Equal(t, Product{Name: "Overridden", Link: "https://example-hermes.com/"}, h.Product)
+	assert.Equal(t, "Hermes", base.Product.Name, "Base configuration should not be modified")
+
+	_, err = RenderRequest{Format: FormatMessage}.Configure(base, Themes())
+	assert.Equal(t, ValidationErrors{{Field: "message", Message: "is required by the eml format"}}, err)
+
+	base.ThemeOptions = ThemeOptions{PrimaryColor: "#FF5722", ContentWidth: 600}
+	h, err = RenderRequest{ThemeOptions: &ThemeOptions{PrimaryColor: "#22BC66"}}.Configure(base, Themes())
+	assert.Nil(t, err)
+	assert.Equal(t, ThemeOptions{PrimaryColor: "#22BC66", ContentWidth: 600}, h.ThemeOptions)
+	_, err = RenderRequest{ThemeOptions: &ThemeOptions{FontFamily: "Arial; } body { display: none"}}.Configure(base, Themes())
+	assert.Equal(t, ValidationErrors{{Field: "themeOptions.fontFamily", Message: "is not a valid CSS value"}}, err)
+}
@@ -0,0 +1,312 @@
+package hermes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/sprig"
+)
+
+// DefaultMaxOutputSize is the maximum size of each version of an email generated in a Sandbox, in bytes, when not configured
+const DefaultMaxOutputSize = 1 << 20
+
+// DefaultSandboxTimeout is the maximum duration of a generation in a Sandbox, when not configured
+const DefaultSandboxTimeout = 5 * time.Second
+
+// ErrOutputTooLarge is returned when a template generates more than the maximum output size of its Sandbox
+var ErrOutputTooLarge = errors.New("hermes: generated email exceeds the maximum output size")
+
+// Sandbox restricts theme templates coming from outside the application binary, e.g. uploaded by users
+// Templates can only use side-effect-free functions: functions reading the environment, e.g. env and expandenv,
+// generating keys or random values, or allocating unbounded memory, e.g. repeat, indent and until, are not defined.
+// The values returned by functions, checked before calling the ones growing faster than their arguments, e.g. replace,
+// the size of the output and the duration of the generation are limited too.
+// Go templates can't be interrupted: once the timeout is reached, the generation fails and the execution of the template
+// is abandoned, unable to write anymore, but keeps running in the background until it ends.
+type Sandbox struct {
+	MaxOutputSize int64         // Maximum size of each version of a generated email, before CSS inlining, in bytes (default to DefaultMaxOutputSize)
+	Timeout       time.Duration // Maximum duration of a generation when the context has no deadline (default to DefaultSandboxTimeout)
+}
+
+// sandboxFuncNames are the functions of sprig available in a Sandbox
+var sandboxFuncNames = []string{
+	// Strings
+	"abbrev", "abbrevboth", "camelcase", "cat", "contains", "hasPrefix", "hasSuffix", "initials", "lower", "nospace", "plural", "quote", "replace", "snakecase", "squote", "substr", "swapcase", "title", "trim",
+	"trimAll", "trimPrefix", "trimSuffix", "trimall", "trunc", "untitle", "upper", "wrap", "wrapWith",
+	"split", "splitList", "splitn", "join", "sortAlpha", "toString", "toStrings",
+	"regexFind", "regexFindAll", "regexMatch", "regexReplaceAll", "regexReplaceAllLiteral", "regexSplit",
+	"b32dec", "b32enc", "b64dec", "b64enc",
+	// Numbers
+	"add", "add1", "atoi", "biggest", "ceil", "div", "float64", "floor", "int", "int64", "max", "min", "mod", "mul",
+	"round", "sub",
+	// Dates
+	"ago", "date", "dateInZone", "dateModify", "date_in_zone", "date_modify", "htmlDate", "htmlDateInZone", "now",
+	"toDate",
+	// Defaults and types
+	"coalesce", "default", "empty", "fail", "kindIs", "kindOf", "ternary", "typeIs", "typeIsLike",
+	"typeOf",
+	// Lists and dictionaries, without the ones modifying a dictionary in place
+	"append", "compact", "dict", "first", "has", "hasKey", "initial", "keys", "last", "list", "omit", "pick", "pluck",
+	"prepend", "push", "rest", "reverse", "slice", "tuple", "uniq", "values", "without",
+}
+
+// sandboxFuncs are the functions of sprig available in a Sandbox, by name, with the printing functions of templates
+var sandboxFuncs = func() template.FuncMap {
+	all := sprig.FuncMap()
+	funcs := template.FuncMap{"print": fmt.Sprint, "printf": fmt.Sprintf, "println": fmt.Sprintln}
+	for _, name := range sandboxFuncNames {
+		funcs[name] = all[name]
+	}
+	return funcs
+}()
+
+// ThemeFromFS loads a theme like ThemeFromFS, checking its templates in the sandbox
+// Emails are always generated in the sandbox with the returned theme, unless Hermes.Sandbox is set.
+func (s Sandbox) ThemeFromFS(fsys fs.FS, dir string) (Theme, error) {
+	return themeFromFS(fsys, dir, &s)
+}
+
+// sandboxGrowth estimates the size of the values returned by the functions of a Sandbox growing faster than their arguments,
+// e.g. {{ replace "" $s $s }}, so that they fail before allocating them
+var sandboxGrowth = map[string]func(args []reflect.Value, limit int64) int64{
+	"replace": func(args []reflect.Value, limit int64) int64 {
+		return replacedSize(args[2].Len(), args[0].Len(), args[1].Len())
+	},
+	"regexReplaceAll": func(args []reflect.Value, limit int64) int64 {
+		// Matches can be expanded in each replacement, e.g. with $0
+		return replacedSize(args[1].Len(), 1, args[2].Len()) + int64(args[1].Len())*int64(args[2].Len())
+	},
+	"regexReplaceAllLiteral": func(args []reflect.Value, limit int64) int64 {
+		return replacedSize(args[1].Len(), 1, args[2].Len())
+	},
+	"wrapWith": func(args []reflect.Value, limit int64) int64 {
+		return replacedSize(args[2].Len(), 1, args[1].Len())
+	},
+	"join": func(args []reflect.Value, limit int64) int64 {
+		size := valueSize(args[1], limit)
+		return size + size*int64(args[0].Len())
+	},
+	"printf": func(args []reflect.Value, limit int64) int64 {
+		size := valueSize(args[1], limit)
+		return int64(args[0].Len()) + size + size*formatWidth(args[0].String(), args[1], limit)
+	},
+}
+
+// funcs returns the functions of sprig available to templates, all of them when s is nil
+// In a sandbox, functions fail with ErrOutputTooLarge when they would return values larger than the maximum output size,
+// e.g. {{ $s = cat $s $s }} in a loop.
+func (s *Sandbox) funcs() template.FuncMap {
+	if s == nil {
+		return sprig.FuncMap()
+	}
+	funcs := make(template.FuncMap, len(sandboxFuncs))
+	for name, f := range sandboxFuncs {
+		funcs[name] = boundFunc(f, sandboxGrowth[name], s.maxOutputSize())
+	}
+	return funcs
+}
+
+// boundFunc returns f, panicking with ErrOutputTooLarge when the size of its result estimated by growth, if any,
+// or the size of its result once printed exceeds limit
+// Panics of functions are returned as errors by the execution of templates.
+func boundFunc(f interface{}, growth func(args []reflect.Value, limit int64) int64, limit int64) interface{} {
+	fn := reflect.ValueOf(f)
+	if fn.Type().NumOut() == 0 {
+		return f
+	}
+	return reflect.MakeFunc(fn.Type(), func(args []reflect.Value) []reflect.Value {
+		if growth != nil && growth(args, limit) > limit {
+			panic(ErrOutputTooLarge)
+		}
+		var results []reflect.Value
+		if fn.Type().IsVariadic() {
+			results = fn.CallSlice(args)
+		} else {
+			results = fn.Call(args)
+		}
+		if valueSize(results[0], limit) > limit {
+			panic(ErrOutputTooLarge)
+		}
+		return results
+	}).Interface()
+}
+
+// replacedSize returns the maximum size of a string of n bytes once all the occurrences of a string of old bytes
+// are replaced with a string of new bytes, an empty string occurring around each byte
+func replacedSize(n, old, new int) int64 {
+	if old == 0 {
+		old = 1
+	}
+	return int64(n) + int64(n/old+1)*int64(new)
+}
+
+// valueSize returns the size of v once printed, in bytes, up to limit+1
+// Strings count for their length, and other values, e.g. numbers or the elements of lists, for a byte.
+// Structs and pointers are not walked: they are given by the application, not built by templates.
+func valueSize(v reflect.Value, limit int64) int64 {
+	var size int64
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.String:
+			size += int64(v.Len())
+		case reflect.Interface:
+			if v.IsNil() {
+				size++
+			} else {
+				walk(v.Elem())
+			}
+		case reflect.Slice, reflect.Array:
+			size++
+			for i := 0; i < v.Len() && size <= limit; i++ {
+				size++
+				walk(v.Index(i))
+			}
+		case reflect.Map:
+			size++
+			iter := v.MapRange()
+			for size <= limit && iter.Next() {
+				size++
+				walk(iter.Key())
+				walk(iter.Value())
+			}
+		default:
+			size++
+		}
+	}
+	walk(v)
+	return size
+}
+
+// formatWidth returns the sum of the widths and precisions of the verbs of a format, e.g. 10 for %-8.2f,
+// widths given with * being counted as the sum of the integers of values, up to limit+1
+func formatWidth(format string, values reflect.Value, limit int64) int64 {
+	var width, n int64
+	for i := 0; i < len(format) && width <= limit; i++ {
+		if format[i] != '%' {
+			continue
+		}
+		for i++; i < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[i]) >= 0 && n <= limit; i++ {
+			switch c := format[i]; {
+			case c >= '0' && c <= '9':
+				n = n*10 + int64(c-'0')
+			case c == '*':
+				for j := 0; j < values.Len(); j++ {
+					if v := reflect.ValueOf(values.Index(j).Interface()); v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64 {
+						if w := v.Int(); w < 0 {
+							width -= w
+						} else {
+							width += w
+						}
+					}
+				}
+			default:
+				width, n = width+n, 0
+			}
+		}
+		width, n = width+n, 0
+	}
+	return width
+}
+
+// maxOutputSize returns the maximum output size of the sandbox, in bytes
+func (s *Sandbox) maxOutputSize() int64 {
+	if s.MaxOutputSize <= 0 {
+		return DefaultMaxOutputSize
+	}
+	return s.MaxOutputSize
+}
+
+// context returns the context of a generation, bound to the timeout of the sandbox when ctx has no deadline
+func (s *Sandbox) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s == nil {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSandboxTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// writer returns w, failing writes once ctx is done or the maximum output size of the sandbox is exceeded
+func (s *Sandbox) writer(ctx context.Context, w io.Writer) *limitedWriter {
+	lw := &limitedWriter{ctx: ctx, w: w, remaining: -1}
+	if s != nil {
+		lw.remaining = s.maxOutputSize()
+	}
+	return lw
+}
+
+// execute executes t with data into w, until ctx is done
+// Without sandbox, the execution is stopped at its next write once ctx is done. In a sandbox, it is abandoned as soon as ctx is done.
+func (s *Sandbox) execute(ctx context.Context, w io.Writer, t *template.Template, data interface{}) error {
+	lw := s.writer(ctx, w)
+	if s == nil {
+		return t.Execute(lw, data)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- t.Execute(lw, data)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		lw.close()
+		return ctx.Err()
+	}
+}
+
+// limitedWriter stops the execution of a template writing to it once its context is done or it wrote too much
+type limitedWriter struct {
+	ctx       context.Context
+	w         io.Writer
+	mu        sync.Mutex
+	closed    bool  // Whether the execution was abandoned, w being no longer writable
+	remaining int64 // Number of bytes still allowed, unlimited when negative
+}
+
+// close prevents any further write to the underlying writer, once the write in progress, if any, is done
+func (w *limitedWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.ctx.Err(); w.closed || err != nil {
+		return 0, err
+	}
+	if w.remaining >= 0 {
+		if int64(len(p)) > w.remaining {
+			return 0, ErrOutputTooLarge
+		}
+		w.remaining -= int64(len(p))
+	}
+	return w.w.Write(p)
+}
+
+// themeSandbox returns the sandbox a theme was loaded in, if any
+func themeSandbox(theme Theme) *Sandbox {
+	switch t := theme.(type) {
+	case *fsTheme:
+		return t.sandbox
+	case *ExtendedTheme:
+		return themeSandbox(t.Base)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package hermes
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandbox_Funcs(t *testing.T) {
+	for _, name := range []string{"env", "expandenv", "repeat", "indent", "nindent", "toJson", "until", "genPrivateKey", "randAlpha", "set"} {
+		theme := &ExtendedTheme{Base: new(Default), HTML: `{{ define "footer" }}{{ ` + name + ` "HOME" }}{{ end }}`}
+		h := Hermes{Theme: theme, Sandbox: &Sandbox{}}
+		_, err := h.GenerateHTML(Email{})
+		if assert.NotNil(t, err, "%s should not be available in a sandbox", name) {
+			assert.Contains(t, err.Error(), `function "`+name+`" not defined`)
+		}
+	}
+	for name := range sandboxFuncs {
+		assert.NotNil(t, sandboxFuncs[name], "%s should be a function of sprig", name)
+	}
+
+	theme := &ExtendedTheme{Base: new(Default), HTML: `{{ define "footer" }}<p>{{ .Hermes.Product.Name | upper | trunc 4 }}</p>{{ end }}`}
+	h := Hermes{Theme: theme, Sandbox: &Sandbox{}}
+	res, err := h.GenerateHTML(Email{})
+	assert.Nil(t, err)
+	assert.Contains(t, res, "HERM")
+}
+
+func TestSandbox_BundledThemes(t *testing.T) {
+	email := Email{Body{
+		Name:    "Jon Snow",
+		Intros:  []string{"Welcome to Hermes!"},
+		Actions: []Action{{Instructions: "To get started:", Button: Button{Text: "Confirm", Link: "https://hermes-example.com/confirm"}}},
+	}}
+	for _, theme := range Themes() {
+		h := Hermes{Theme: theme}
+		expected, err := h.Generate(email)
+		assert.Nil(t, err)
+
+		h = Hermes{Theme: theme, Sandbox: &Sandbox{}}
+		sandboxed, err := h.Generate(email)
+		assert.Nil(t, err, "%s theme should be rendered in a sandbox", theme.Name())
+		assert.Equal(t, expected.HTML, sandboxed.HTML)
+		assert.Equal(t, expected.Text, sandboxed.Text)
+	}
+}
+
+func TestSandbox_Limits(t *testing.T) {
+	email := Email{Body{Intros: []string{strings.Repeat("Welcome to Hermes! ", 1000)}}}
+
+	h := Hermes{Sandbox: &Sandbox{MaxOutputSize: 10000}}
+	_, err := h.Generate(email)
+	assert.True(t, errors.Is(err, ErrOutputTooLarge), "Generation should fail above the maximum output size: %v", err)
+	_, err = h.GeneratePlainText(email)
+	assert.True(t, errors.Is(err, ErrOutputTooLarge), "Generation should fail above the maximum output size: %v", err)
+
+	h = Hermes{Sandbox: &Sandbox{}, DisableCSSInlining: true}
+	_, err = h.GenerateHTML(email)
+	assert.Nil(t, err)
+
+	h = Hermes{Sandbox: &Sandbox{Timeout: time.Nanosecond}}
+	_, err = h.Generate(email)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Generation should fail after the timeout: %v", err)
+
+	// Loops not writing anything are abandoned after the timeout
+	loops := `{{ $l := list 0 1 2 3 4 5 6 7 8 9 }}` + strings.Repeat(`{{ range $l }}`, 7) + strings.Repeat(`{{ end }}`, 7)
+	h = Hermes{Theme: &ExtendedTheme{Base: new(Default), HTML: `{{ define "footer" }}` + loops + `{{ end }}`}, Sandbox: &Sandbox{Timeout: 20 * time.Millisecond}}
+	start := time.Now()
+	_, err = h.GenerateHTML(email)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Generation should fail after the timeout: %v", err)
+	assert.True(t, time.Since(start) < 200*time.Millisecond, "Generation should stop at the timeout, not at the next write")
+
+	doubling := `{{ $s := "Hermes" }}{{ $l := list 0 1 2 3 4 5 6 7 8 9 }}{{ range $l }}{{ range $l }}{{ $s = cat $s $s }}{{ end }}{{ end }}`
+	h = Hermes{Theme: &ExtendedTheme{Base: new(Default), HTML: `{{ define "footer" }}` + doubling + `{{ end }}`}, Sandbox: &Sandbox{}}
+	_, err = h.GenerateHTML(email)
+	assert.True(t, errors.Is(err, ErrOutputTooLarge), "Functions should not return strings larger than the maximum output size: %v", err)
+	h.Theme = &ExtendedTheme{Base: new(Default), HTML: `{{ define "footer" }}` + strings.Replace(doubling, "cat $s $s", `printf "%s%s" $s $s`, 1) + `{{ end }}`}
+	_, err = h.GenerateHTML(email)
+	assert.True(t, errors.Is(err, ErrOutputTooLarge), "Printing functions should be limited too: %v", err)
+
+	// Functions growing faster than their arguments fail before allocating their result
+	s := `{{ $s := "Hermes" }}{{ range list 0 1 2 3 4 5 6 7 8 9 }}{{ $s = cat $s $s }}{{ end }}`
+	for _, footer := range []string{
+		s + `{{ replace "" $s $s }}`,
+		s + `{{ regexReplaceAll "" $s $s }}`,
+		s + `{{ regexReplaceAllLiteral "" $s $s }}`,
+		s + `{{ wrapWith 1 $s $s }}`,
+		s + `{{ join $s (splitList "" $s) }}`,
+		s + `{{ printf "%9999s" (splitList "" $s) }}`,
+		`{{ $l := list 0 }}` + strings.Repeat(`{{ $l = list $l $l }}`, 24) + `{{ $l }}`,
+	} {
+		h.Theme = &ExtendedTheme{Base: new(Default), HTML: `{{ define "footer" }}` + footer + `{{ end }}`}
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err = h.GenerateHTML(email)
+		runtime.ReadMemStats(&after)
+		assert.True(t, errors.Is(err, ErrOutputTooLarge), "%s should fail: %v", footer, err)
+		assert.True(t, after.TotalAlloc-before.TotalAlloc < 10<<20, "%s should not allocate its result, allocated %d bytes", footer, after.TotalAlloc-before.TotalAlloc)
+	}
+	h.Theme = &ExtendedTheme{Base: new(Default), HTML: `{{ define "footer" }}` + s + `<p>{{ replace "Hermes" "Hermès" $s | trunc 7 }} {{ printf "%-8.2f|" 3.14159 }}</p>{{ end }}`}
+	res, err := h.GenerateHTML(email)
+	assert.Nil(t, err)
+	assert.Contains(t, res, "Hermès 3.14    |")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h = Hermes{}
+	_, err = h.GenerateContext(ctx, email)
+	assert.True(t, errors.Is(err, context.Canceled), "Generation should stop once the context is done: %v", err)
+}
+
+func TestSandbox_ThemeFromFS(t *testing.T) {
+	sandbox := Sandbox{MaxOutputSize: 100000}
+	theme, err := sandbox.ThemeFromFS(os.DirFS("testdata/themes"), "simple")
+	assert.Nil(t, err)
+	r, err := NewRenderer(Hermes{Theme: theme})
+	assert.Nil(t, err)
+	assert.Equal(t, &sandbox, r.Hermes().Sandbox, "Emails should be generated in the sandbox the theme was loaded in")
+	r, err = NewRenderer(Hermes{Theme: &ExtendedTheme{Base: theme}})
+	assert.Nil(t, err)
+	assert.Equal(t, &sandbox, r.Hermes().Sandbox, "Extended themes should be generated in the sandbox of their base theme")
+
+	fsys := fstest.MapFS{
+		"theme.yaml": {Data: []byte("name: secrets")},
+		"html.tmpl":  {Data: []byte(`<p>{{ env "SMTP_PASSWORD" }}</p>`)},
+		"text.tmpl":  {Data: []byte(`{{ .Email.Body.Name }}`)},
+	}
+	_, err = ThemeFromFS(fsys, ".")
+	assert.Nil(t, err)
+	_, err = sandbox.ThemeFromFS(fsys, ".")
+	themeErr, ok := err.(*ThemeError)
+	if assert.True(t, ok, "Error should be a ThemeError: %v", err) {
+		assert.Equal(t, "html.tmpl", themeErr.File)
+	}
+}
@@ -0,0 +1,153 @@
+package hermes
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLPolicy is an allowlist of the HTML elements and attributes of markdown content, e.g. Body.FreeMarkdown
+// Elements not allowed are replaced by their content, except script, style, iframe and the like, removed with their content.
+// Attributes not allowed are removed, and the URLs of allowed href and src attributes are checked with Hermes.URLPolicy.
+type HTMLPolicy struct {
+	Elements   []string            // Allowed elements, e.g. p and a
+	Attributes map[string][]string // Allowed attributes by element, or for all elements with "*", e.g. {"a": {"href", "title"}}
+	LinkRel    string              // rel attribute set on all links, e.g. noopener noreferrer (default to none)
+	LinkTarget string              // target attribute set on all links, e.g. _blank (default to none)
+}
+
+// DefaultHTMLPolicy returns the policy of Hermes without HTMLPolicy, allowing the HTML generated from markdown
+func DefaultHTMLPolicy() HTMLPolicy {
+	return HTMLPolicy{
+		Elements: []string{
+			"a", "b", "blockquote", "br", "code", "dd", "del", "div", "dl", "dt", "em", "h1", "h2", "h3", "h4", "h5", "h6",
+			"hr", "i", "img", "ins", "li", "ol", "p", "pre", "s", "small", "span", "strong", "sub", "sup", "table", "tbody",
+			"td", "tfoot", "th", "thead", "tr", "u", "ul",
+		},
+		Attributes: map[string][]string{
+			"a":    {"href", "title"},
+			"img":  {"src", "alt", "title", "width", "height"},
+			"code": {"class"},
+			"ol":   {"start"},
+			"td":   {"align", "colspan", "rowspan"},
+			"th":   {"align", "colspan", "rowspan"},
+		},
+	}
+}
+
+// unsafeElements are removed with their content when not allowed, since their content is not meant to be displayed
+var unsafeElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Frame: true, atom.Frameset: true, atom.Object: true,
+	atom.Embed: true, atom.Noscript: true, atom.Template: true, atom.Textarea: true, atom.Select: true, atom.Title: true,
+	atom.Svg: true, atom.Math: true,
+}
+
+// urlAttributes are the attributes containing a URL, checked with the URL policy
+var urlAttributes = map[string]bool{"href": true, "src": true, "cite": true, "background": true, "poster": true}
+
+// sanitizer removes the elements and attributes of HTML content not allowed by its policies
+type sanitizer struct {
+	policy  HTMLPolicy
+	urls    URLPolicy
+	trusted map[string]bool   // Attributes allowed whatever the policy, by key=value, e.g. the styles of the markdown renderer
+	errs    *ValidationErrors // Disallowed URLs, reported on field unless rewritten by the URL policy
+	field   string
+}
+
+// sanitize returns the content allowed by the policies, disallowed URLs being replaced with "#"
+// Disallowed URLs are also reported on field in errs, if any, unless the URL policy rewrites them.
+func (s *sanitizer) sanitize(content string) (string, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), context)
+	if err != nil {
+		return "", err
+	}
+	for _, n := range nodes {
+		context.AppendChild(n)
+	}
+	s.sanitizeChildren(context)
+	var b strings.Builder
+	for n := context.FirstChild; n != nil; n = n.NextSibling {
+		if err := html.Render(&b, n); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// sanitizeChildren sanitizes the children of n
+func (s *sanitizer) sanitizeChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.ElementNode:
+			if containsFold(s.policy.Elements, c.Data) {
+				s.sanitizeAttributes(c)
+				s.sanitizeChildren(c)
+				break
+			}
+			if !unsafeElements[c.DataAtom] {
+				// Elements not allowed are replaced by their sanitized content
+				s.sanitizeChildren(c)
+				for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+					c.RemoveChild(gc)
+					n.InsertBefore(gc, c)
+				}
+			}
+			n.RemoveChild(c)
+		case html.TextNode:
+		default:
+			// Comments and doctypes
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+// sanitizeAttributes removes the attributes of n not allowed by the policy, and checks its URLs
+func (s *sanitizer) sanitizeAttributes(n *html.Node) {
+	var attrs []html.Attribute
+	for _, attr := range n.Attr {
+		key := strings.ToLower(attr.Key)
+		allowed := containsFold(s.policy.Attributes["*"], key) || containsFold(s.policy.Attributes[n.Data], key) || s.trusted[key+"="+attr.Val]
+		if attr.Namespace != "" || !allowed {
+			continue
+		}
+		if urlAttributes[key] {
+			attr.Val = s.checkURL(attr.Val)
+		}
+		attrs = append(attrs, attr)
+	}
+	if n.DataAtom == atom.A {
+		for _, link := range []html.Attribute{{Key: "rel", Val: s.policy.LinkRel}, {Key: "target", Val: s.policy.LinkTarget}} {
+			if link.Val != "" {
+				attrs = setAttribute(attrs, link)
+			}
+		}
+	}
+	n.Attr = attrs
+}
+
+// checkURL returns u when allowed by the URL policy, and "#" otherwise
+func (s *sanitizer) checkURL(u string) string {
+	err := s.urls.Check(strings.TrimSpace(u))
+	if err == nil {
+		return u
+	}
+	if s.errs != nil && !s.urls.Rewrite {
+		s.errs.add(s.field, "URL %q %v", u, err)
+	}
+	return "#"
+}
+
+// setAttribute replaces the attribute with the same key in attrs, or adds it
+func setAttribute(attrs []html.Attribute, attr html.Attribute) []html.Attribute {
+	for i := range attrs {
+		if strings.EqualFold(attrs[i].Key, attr.Key) {
+			attrs[i] = attr
+			return attrs
+		}
+	}
+	return append(attrs, attr)
+}
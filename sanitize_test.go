@@ -0,0 +1,69 @@
+package hermes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"Scripts are removed with their content", "<p>Hello<script>alert(1)</script></p>", "<p>Hello</p>"},
+		{"Iframes are removed with their content", `<iframe src="https://evil.com">Hello</iframe>`, ""},
+		{"Event handlers are removed", `<p onclick="alert(1)" onmouseover="alert(2)">Hello</p>`, "<p>Hello</p>"},
+		{"Styles are removed", `<p style="background: url(javascript:alert(1))">Hello</p>`, "<p>Hello</p>"},
+		{"Unknown elements are replaced by their content", `<p><font color="red">Hello <b onclick="alert(1)">world</b></font></p>`, "<p>Hello <b>world</b></p>"},
+		{"Comments are removed", "<p><!-- secret -->Hello</p>", "<p>Hello</p>"},
+		{"Javascript links are replaced", `<a href=" JavaScript:alert(1)" title="click">click</a>`, `<a href="#" title="click">click</a>`},
+		{"Data images are replaced", `<img src="data:image/svg+xml;base64,PHN2Zz4=" alt="x"/>`, `<img src="#" alt="x"/>`},
+		{"Inline images are allowed", `<img src="cid:chart.png" alt="Chart"/>`, `<img src="cid:chart.png" alt="Chart"/>`},
+		{"Text is escaped", `<p>&lt;script&gt;</p>`, `<p>&lt;script&gt;</p>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := (&sanitizer{policy: DefaultHTMLPolicy()}).sanitize(test.content)
+			assert.Nil(t, err)
+			assert.Equal(t, test.want, res)
+		})
+	}
+}
+
+func TestMarkdown_ToHTML(t *testing.T) {
+	assert.Equal(t, `<p>Hello <em>world</em>, <a href="https://hermes-example.com">click</a></p>`, strings.TrimSpace(string(Markdown("Hello *world*, [click](https://hermes-example.com)").ToHTML())))
+	assert.Equal(t, `<p>Hello <a href="#">click</a></p>`, strings.TrimSpace(string(Markdown("Hello [click](javascript:evil)<script>alert(1)</script>").ToHTML())))
+	assert.NotContains(t, Markdown("<script>\nalert(1)\n</script>").ToHTML(), "alert", "Script blocks should be removed")
+	assert.Regexp(t, `<td align="center" style="[^"]*">1</td>`, Markdown("| A |\n|:-:|\n| 1 |").ToHTML(), "Alignment of table cells should be kept")
+}
+
+func TestHermes_HTMLPolicy(t *testing.T) {
+	email := Email{Body{FreeMarkdown: `[Confirm](https://hermes-example.com/confirm) <span style="color: red" class="warning">now</span>`}}
+	policy := DefaultHTMLPolicy()
+	policy.Attributes["span"] = []string{"style"}
+	policy.LinkRel = "noopener noreferrer"
+	policy.LinkTarget = "_blank"
+	for _, theme := range Themes() {
+		h := Hermes{Theme: theme, HTMLPolicy: &policy, DisableCSSInlining: true}
+		res, err := h.GenerateHTML(email)
+		assert.Nil(t, err)
+		assert.Contains(t, res, `<a href="https://hermes-example.com/confirm" rel="noopener noreferrer" target="_blank">Confirm</a>`)
+		assert.Contains(t, res, `<span style="color: red">now</span>`)
+	}
+
+	email = Email{Body{FreeMarkdown: "[Confirm](javascript:evil)"}}
+	h := Hermes{}
+	_, err := h.Generate(email)
+	errs, ok := err.(ValidationErrors)
+	if assert.True(t, ok, "Error should be a ValidationErrors: %v", err) && assert.Len(t, errs, 1) {
+		assert.Equal(t, "body.freeMarkdown", errs[0].Field)
+		assert.Equal(t, `URL "javascript:evil" has a disallowed scheme "javascript"`, errs[0].Message)
+	}
+	h = Hermes{URLPolicy: URLPolicy{Rewrite: true}}
+	rendered, err := h.Generate(email)
+	assert.Nil(t, err)
+	assert.NotContains(t, rendered.HTML, "javascript:")
+}
@@ -0,0 +1,163 @@
+package hermes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxRequestSize is the default maximum size of a request body handled by Server
+const DefaultMaxRequestSize = 10 << 20
+
+// Server is an http.Handler rendering emails sent as JSON, for services not written in Go
+//
+// Routes are:
+//
+//	POST /render   renders a RenderRequest
+//	GET  /themes   lists the available themes
+//	GET  /health   reports that the server is up
+//
+// Mount it under a prefix with http.StripPrefix.
+type Server struct {
+	Hermes         Hermes  // Configuration used to render emails, overridden by requests
+	Themes         []Theme // Themes requests can choose from (default to bundled themes)
+	MaxRequestSize int64   // Maximum size of a request body, in bytes (default to DefaultMaxRequestSize)
+}
+
+// ErrorResponse is the JSON body of an error response
+type ErrorResponse struct {
+	Error  string           `json:"error"`
+	Fields ValidationErrors `json:"fields,omitempty"` // Invalid fields of the request, if any
+}
+
+// NewServer creates a Server rendering emails with the given configuration
+func NewServer(h Hermes) *Server {
+	return &Server{Hermes: h}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var method string
+	var handler func(http.ResponseWriter, *http.Request)
+	switch r.URL.Path {
+	case "/render":
+		method, handler = http.MethodPost, s.render
+	case "/themes":
+		method, handler = http.MethodGet, s.themes
+	case "/health":
+		method, handler = http.MethodGet, s.health
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	handler(w, r)
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) themes(w http.ResponseWriter, r *http.Request) {
+	names := []string{}
+	for _, t := range s.availableThemes() {
+		names = append(names, t.Name())
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"themes": names})
+}
+
+func (s *Server) render(w http.ResponseWriter, r *http.Request) {
+	maxSize := s.MaxRequestSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxRequestSize
+	}
+	var req RenderRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSize))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.Contains(err.Error(), "request body too large") {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, fmt.Errorf("invalid JSON: %v", err))
+		return
+	}
+
+	h, err := req.Configure(s.Hermes, s.availableThemes())
+	if errs, ok := err.(ValidationErrors); ok {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: "invalid request", Fields: errs})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	rendered, err := h.GenerateContext(r.Context(), req.Email)
+	if errs, ok := err.(ValidationErrors); ok {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: "invalid request", Fields: requestFields(errs)})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	switch req.Format {
+	case FormatPlainText:
+		writeContent(w, "text/plain; charset=utf-8", []byte(rendered.Text))
+	case FormatMessage:
+		m := req.Message.Message(rendered)
+		var b bytes.Buffer
+		_, err = m.WriteTo(&b)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		writeContent(w, "message/rfc822", b.Bytes())
+	default:
+		writeContent(w, "text/html; charset=utf-8", []byte(rendered.HTML))
+	}
+}
+
+func (s *Server) availableThemes() []Theme {
+	if len(s.Themes) > 0 {
+		return s.Themes
+	}
+	return Themes()
+}
+
+// requestFields names the fields of errors found while generating after the fields of the request
+func requestFields(errs ValidationErrors) ValidationErrors {
+	var named ValidationErrors
+	for _, e := range errs {
+		field := e.Field
+		if strings.HasPrefix(field, "body.") {
+			field = "email." + field
+		}
+		named = append(named, &ValidationError{Field: field, Message: e.Message})
+	}
+	return named
+}
+
+func writeContent(w http.ResponseWriter, contentType string, content []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: strings.TrimPrefix(err.Error(), "hermes: ")})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
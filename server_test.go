@@ -0,0 +1,171 @@
+package hermes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(t *testing.T, s *Server, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func decodeErrorResponse(t *testing.T, w *httptest.ResponseRecorder) ErrorResponse {
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var res ErrorResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&res))
+	return res
+}
+
+const renderRequestEmail = `"email": {
+	"body": {
+		"name": "Jon Snow",
+		"intros": ["Welcome to Hermes! We're very excited to have you on board."],
+		"actions": [{
+			"instructions": "To get started with Hermes, please click here:",
+			"button": {"text": "Confirm your account", "link": "https://hermes-example.com/confirm?token=d9729feb74992cc3482b350163a1a010"}
+		}]
+	}
+}`
+
+func TestServer_RenderHTML(t *testing.T) {
+	s := NewServer(Hermes{Product: Product{Name: "HermesServer", Link: "http://hermes.com"}})
+	w := serve(t, s, http.MethodPost, "/render", `{"theme": "flat", "product": {"name": "Overridden"}, `+renderRequestEmail+`}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Jon Snow")
+	assert.Contains(t, w.Body.String(), "Overridden", "Product should be overridden")
+	assert.Contains(t, w.Body.String(), "http://hermes.com", "Product fields not overridden should be kept")
+	assert.Contains(t, w.Body.String(), "https://hermes-example.com/confirm?token=d9729feb74992cc3482b350163a1a010")
+
+	flat, err := (&Hermes{Theme: new(Flat), Product: Product{Name: "Overridden", Link: "http://hermes.com"}}).GenerateHTML(Email{Body: Body{
+		Name:   "Jon Snow",
+		Intros: []string{"Welcome to Hermes! We're very excited to have you on board."},
+		Actions: []Action{{
+			Instructions: "To get started with Hermes, please click here:",
+			Button:       Button{Text: "Confirm your account", Link: "https://hermes-example.com/confirm?token=d9729feb74992cc3482b350163a1a010"},
+		}},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, flat, w.Body.String(), "Should render the email as the Go API does")
+}
+
+func TestServer_RenderPlainText(t *testing.T) {
+	w := serve(t, NewServer(Hermes{}), http.MethodPost, "/render", `{"format": "text", "textDirection": "rtl", `+renderRequestEmail+`}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Hi Jon Snow,")
+	assert.NotContains(t, w.Body.String(), "<table")
+}
+
+func TestServer_RenderMessage(t *testing.T) {
+	w := serve(t, NewServer(Hermes{}), http.MethodPost, "/render", `{
+		"format": "eml",
+		"message": {
+			"from": "Hermes <hermes@example.com>",
+			"to": ["Jon Snow <jon@example.com>"],
+			"subject": "Welcome",
+			"attachments": [{"name": "notes.txt", "content": "V2ludGVyIGlzIGNvbWluZw=="}]
+		},
+		`+renderRequestEmail+`
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "message/rfc822", w.Header().Get("Content-Type"))
+	parsed, err := mail.ReadMessage(w.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "Welcome", parsed.Header.Get("Subject"))
+	assert.Contains(t, parsed.Header.Get("Content-Type"), "multipart/mixed")
+}
+
+func TestServer_RenderInvalid(t *testing.T) {
+	s := NewServer(Hermes{})
+
+	w := serve(t, s, http.MethodPost, "/render", `{"email": `)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, decodeErrorResponse(t, w).Error, "invalid JSON")
+
+	w = serve(t, s, http.MethodPost, "/render", `{"unknown": true}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Unknown fields should be rejected")
+
+	w = serve(t, s, http.MethodPost, "/render", `{
+		"theme": "unknown",
+		"textDirection": "ttb",
+		"format": "eml",
+		"message": {"subject": "Welcome"},
+		"email": {"body": {"actions": [{"button": {"text": "Confirm"}}]}}
+	}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	res := decodeErrorResponse(t, w)
+	assert.Equal(t, "invalid request", res.Error)
+	var fields []string
+	for _, f := range res.Fields {
+		fields = append(fields, f.Field)
+		assert.NotEmpty(t, f.Message)
+	}
+	assert.Equal(t, []string{"theme", "textDirection", "message.from", "message.to", "email.body.actions[0].button.link"}, fields)
+
+	w = serve(t, s, http.MethodPost, "/render", `{
+		"format": "eml",
+		"message": {"from": "hermes@example.com", "to": ["jon@example.com"], "messageId": "x@y>\r\nBcc: victim@example.com\r\nX-Injected: <z"},
+		`+renderRequestEmail+`
+	}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "Message IDs should not inject header fields")
+	res = decodeErrorResponse(t, w)
+	if assert.Len(t, res.Fields, 1) {
+		assert.Equal(t, "message.messageId", res.Fields[0].Field)
+		assert.Equal(t, `contains the invalid character '>'`, res.Fields[0].Message)
+	}
+
+	w = serve(t, s, http.MethodPost, "/render", `{
+		"product": {"link": "javascript:alert(1)"},
+		"email": {"body": {"actions": [{"button": {"text": "Confirm", "link": "javascript:alert(1)"}}]}}
+	}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "URLs not allowed by the policy should be rejected")
+	res = decodeErrorResponse(t, w)
+	assert.Equal(t, "invalid request", res.Error)
+	fields = nil
+	for _, f := range res.Fields {
+		fields = append(fields, f.Field+": "+f.Message)
+	}
+	assert.Equal(t, []string{`product.link: has a disallowed scheme "javascript"`, `email.body.actions[0].button.link: has a disallowed scheme "javascript"`}, fields)
+
+	w = serve(t, s, http.MethodPost, "/render", `{"format": "pdf"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "format", decodeErrorResponse(t, w).Fields[0].Field)
+
+	s.MaxRequestSize = 10
+	w = serve(t, s, http.MethodPost, "/render", `{"format": "text"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestServer_Routes(t *testing.T) {
+	s := &Server{Themes: []Theme{new(Flat)}}
+
+	w := serve(t, s, http.MethodGet, "/health", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, w.Body.String())
+
+	w = serve(t, s, http.MethodGet, "/themes", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"themes": ["flat"]}`, w.Body.String())
+	w = serve(t, NewServer(Hermes{}), http.MethodGet, "/themes", "")
+	assert.JSONEq(t, `{"themes": ["default", "flat"]}`, w.Body.String())
+
+	w = serve(t, s, http.MethodPost, "/render", `{"theme": "default"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "Only configured themes should be available")
+
+	w = serve(t, s, http.MethodGet, "/render", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, http.MethodPost, w.Header().Get("Allow"))
+
+	w = serve(t, s, http.MethodGet, "/unknown", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "not found", decodeErrorResponse(t, w).Error)
+}
@@ -0,0 +1,461 @@
+package hermes
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/imdario/mergo"
+	"gopkg.in/yaml.v2"
+)
+
+// ThemeManifestFile is the name of the manifest of a theme loaded with ThemeFromFS
+const ThemeManifestFile = "theme.yaml"
+
+// ThemeManifest describes the files of a theme loaded with ThemeFromFS
+// Paths are relative to the theme directory.
+type ThemeManifest struct {
+	Name       string   `yaml:"name"`       // Name of the theme (default to the name of its directory)
+	HTML       string   `yaml:"html"`       // HTML template (default to html.tmpl)
+	PlainText  string   `yaml:"plainText"`  // Plain text template (default to text.tmpl)
+	Stylesheet string   `yaml:"stylesheet"` // Optional stylesheet template, included with {{template "stylesheet" .}}
+	Partials   []string `yaml:"partials"`   // Optional glob patterns of partial templates, invoked with {{template "<file name without extension>" .}}
+}
+
+// PartialsTheme is implemented by themes made of several templates
+// Partial templates are available to both the HTML and the plain text templates.
+type PartialsTheme interface {
+	Theme
+	Partials() map[string]string // Partial templates, by name
+}
+
+// StylesheetTheme is implemented by themes exposing their stylesheet separately from their markup
+// The stylesheet is available to templates as the "stylesheet" template.
+type StylesheetTheme interface {
+	Theme
+	Stylesheet() string // CSS of the theme, which can use template actions
+}
+
+// OptionsTheme is implemented by themes customizable with ThemeOptions
+type OptionsTheme interface {
+	Theme
+	DefaultOptions() ThemeOptions // Options giving the original look of the theme
+}
+
+// ThemeOptions customizes the look of themes implementing OptionsTheme, i.e. bundled themes
+// Zero values are replaced by the defaults of the theme.
+// Templates read them from .Hermes.ThemeOptions, colors and fonts being written with the css function, e.g.
+// {{ .Hermes.ThemeOptions.PrimaryColor | css }}.
+type ThemeOptions struct {
+	PrimaryColor         string `json:"primaryColor"`         // Color of buttons
+	ButtonTextColor      string `json:"buttonTextColor"`      // Color of the text of buttons
+	ButtonHoverColor     string `json:"buttonHoverColor"`     // Color of hovered buttons, in email clients supporting it (default to none)
+	SecondaryColor       string `json:"secondaryColor"`       // Color of titles and of the product name
+	BackgroundColor      string `json:"backgroundColor"`      // Color around the body of the email
+	TextColor            string `json:"textColor"`            // Color of the text
+	LinkColor            string `json:"linkColor"`            // Color of links
+	BorderColor          string `json:"borderColor"`          // Color of the borders of the body, tables and markdown content
+	MutedBackgroundColor string `json:"mutedBackgroundColor"` // Color behind invite codes, code and the headers of markdown tables
+	FontFamily           string `json:"fontFamily"`           // Font stack of the text, e.g. Arial, 'Helvetica Neue', Helvetica, sans-serif
+	MonospaceFontFamily  string `json:"monospaceFontFamily"`  // Font stack of invite codes
+	ContentWidth         int    `json:"contentWidth"`         // Width of the body of the email, in pixels
+	BorderRadius         int    `json:"borderRadius"`         // Radius of the corners of buttons and invite codes, in pixels (negative for square corners)
+	ButtonHeight         int    `json:"buttonHeight"`         // Height of buttons, in pixels
+
+	// Dark mode, when the email client prefers it
+	DisableDarkMode            bool   `json:"disableDarkMode"`            // Whether to leave email clients in dark mode invert colors by themselves
+	DarkBackgroundColor        string `json:"darkBackgroundColor"`        // Color around the body of the email
+	DarkContentBackgroundColor string `json:"darkContentBackgroundColor"` // Color behind the body of the email
+	DarkTextColor              string `json:"darkTextColor"`              // Color of the text
+	DarkSecondaryColor         string `json:"darkSecondaryColor"`         // Color of titles and of the product name
+	DarkLinkColor              string `json:"darkLinkColor"`              // Color of links
+}
+
+// themeOptions returns the options of the theme of h, completed with the defaults of the theme
+func themeOptions(h Hermes) (ThemeOptions, error) {
+	options := h.ThemeOptions
+	if theme, ok := h.Theme.(OptionsTheme); ok {
+		err := mergo.Merge(&options, theme.DefaultOptions())
+		if err != nil {
+			return options, err
+		}
+	}
+	if options.BorderRadius < 0 {
+		options.BorderRadius = 0
+	}
+	// Text of buttons follows the primary color given by the user
+	if h.ThemeOptions.PrimaryColor != "" && h.ThemeOptions.ButtonTextColor == "" {
+		options.ButtonTextColor = readableText(options.PrimaryColor, options.ButtonTextColor)
+	}
+	return options, nil
+}
+
+// validate checks that options can be written in a stylesheet as is
+func (o ThemeOptions) validate(errs *ValidationErrors, field string) {
+	for _, option := range []struct {
+		name  string
+		value string
+	}{
+		{"primaryColor", o.PrimaryColor},
+		{"buttonTextColor", o.ButtonTextColor},
+		{"buttonHoverColor", o.ButtonHoverColor},
+		{"secondaryColor", o.SecondaryColor},
+		{"backgroundColor", o.BackgroundColor},
+		{"textColor", o.TextColor},
+		{"linkColor", o.LinkColor},
+		{"borderColor", o.BorderColor},
+		{"mutedBackgroundColor", o.MutedBackgroundColor},
+		{"fontFamily", o.FontFamily},
+		{"monospaceFontFamily", o.MonospaceFontFamily},
+		{"darkBackgroundColor", o.DarkBackgroundColor},
+		{"darkContentBackgroundColor", o.DarkContentBackgroundColor},
+		{"darkTextColor", o.DarkTextColor},
+		{"darkSecondaryColor", o.DarkSecondaryColor},
+		{"darkLinkColor", o.DarkLinkColor},
+	} {
+		if !validCSSValue(option.value) {
+			errs.add(field+"."+option.name, "is not a valid CSS value")
+		}
+	}
+	for _, option := range []struct {
+		name  string
+		value int
+	}{
+		{"contentWidth", o.ContentWidth},
+		{"buttonHeight", o.ButtonHeight},
+	} {
+		if option.value < 0 {
+			errs.add(field+"."+option.name, "must be positive")
+		}
+	}
+}
+
+// ThemeError is an error in a file of a theme loaded with ThemeFromFS
+type ThemeError struct {
+	Theme string // Directory of the theme
+	File  string // Path of the invalid file, relative to the theme directory
+	Err   error
+}
+
+func (e *ThemeError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("hermes: theme %s: %v", e.Theme, e.Err)
+	}
+	return fmt.Sprintf("hermes: theme %s: %s: %v", e.Theme, e.File, e.Err)
+}
+
+func (e *ThemeError) Unwrap() error {
+	return e.Err
+}
+
+// fsTheme is a theme loaded with ThemeFromFS
+type fsTheme struct {
+	name       string
+	html       string
+	plainText  string
+	stylesheet string
+	partials   map[string]string
+	sandbox    *Sandbox // Sandbox the theme was loaded in, if any
+}
+
+// validCSSValue returns whether value can't end the declaration or the rule it is written in
+func validCSSValue(value string) bool {
+	return !strings.ContainsAny(value, ";{}<>\\\"\n\r") && !strings.Contains(value, "/*")
+}
+
+// ThemeFromFS loads the theme of directory dir of fsys, described by its theme.yaml manifest, e.g.:
+//
+//	name: mytheme
+//	html: html.tmpl
+//	plainText: text.tmpl
+//	stylesheet: style.css
+//	partials:
+//	  - partials/*.tmpl
+//
+// Paths of the manifest are relative to dir, and can't leave it.
+// Templates are parsed and executed once with an empty email, so that invalid themes are reported at load time.
+// Use os.DirFS to load a theme from disk, or an embed.FS to embed it in the application binary.
+// Themes coming from outside the application, e.g. uploaded by users, should be loaded with Sandbox.ThemeFromFS instead.
+func ThemeFromFS(fsys fs.FS, dir string) (Theme, error) {
+	return themeFromFS(fsys, dir, nil)
+}
+
+// themeFromFS loads a theme with ThemeFromFS, checking its templates in the sandbox if any
+func themeFromFS(fsys fs.FS, dir string, sandbox *Sandbox) (Theme, error) {
+	themeError := func(file string, err error) error {
+		return &ThemeError{Theme: dir, File: file, Err: err}
+	}
+	// Files are read in the directory of the theme only: paths leaving it, e.g. ../secret.txt, are invalid
+	themeFS, err := fs.Sub(fsys, path.Clean(dir))
+	if err != nil {
+		return nil, themeError(ThemeManifestFile, err)
+	}
+	read := func(file string) (string, error) {
+		content, err := fs.ReadFile(themeFS, path.Clean(file))
+		if err != nil {
+			return "", themeError(file, err)
+		}
+		return string(content), nil
+	}
+
+	content, err := read(ThemeManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ThemeManifest
+	err = yaml.UnmarshalStrict([]byte(content), &manifest)
+	if err != nil {
+		return nil, themeError(ThemeManifestFile, err)
+	}
+	if manifest.Name == "" {
+		manifest.Name = path.Base(dir)
+	}
+	if manifest.Name == "." || manifest.Name == "/" {
+		return nil, themeError(ThemeManifestFile, fmt.Errorf("name is required"))
+	}
+	if manifest.HTML == "" {
+		manifest.HTML = "html.tmpl"
+	}
+	if manifest.PlainText == "" {
+		manifest.PlainText = "text.tmpl"
+	}
+
+	t := &fsTheme{name: manifest.Name, partials: make(map[string]string), sandbox: sandbox}
+	t.html, err = read(manifest.HTML)
+	if err != nil {
+		return nil, err
+	}
+	t.plainText, err = read(manifest.PlainText)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Stylesheet != "" {
+		t.stylesheet, err = read(manifest.Stylesheet)
+		if err != nil {
+			return nil, err
+		}
+	}
+	files := make(map[string]string)
+	for _, pattern := range manifest.Partials {
+		if !fs.ValidPath(path.Clean(pattern)) {
+			return nil, themeError(ThemeManifestFile, fmt.Errorf("partials %q are outside of the theme directory", pattern))
+		}
+		matches, err := fs.Glob(themeFS, path.Clean(pattern))
+		if err != nil {
+			return nil, themeError(ThemeManifestFile, err)
+		}
+		if len(matches) == 0 {
+			return nil, themeError(ThemeManifestFile, fmt.Errorf("no partial matches %q", pattern))
+		}
+		for _, file := range matches {
+			name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+			if other, ok := files[name]; ok && other != file {
+				return nil, themeError(file, fmt.Errorf("partial %q is already defined by %s", name, other))
+			}
+			if name == "stylesheet" {
+				return nil, themeError(file, fmt.Errorf("partial name %q is reserved", name))
+			}
+			files[name] = file
+			t.partials[name], err = read(file)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Partials are reported with their own file, main templates with theirs
+	for _, name := range sortedKeys(files) {
+		if _, err := parseTemplate(t.partials[name], sandbox); err != nil {
+			return nil, themeError(files[name], err)
+		}
+	}
+	if manifest.Stylesheet != "" {
+		if _, err := parseTemplate(t.stylesheet, sandbox); err != nil {
+			return nil, themeError(manifest.Stylesheet, err)
+		}
+	}
+	h := Hermes{Theme: t}
+	err = setDefaultHermesValues(&h)
+	if err != nil {
+		return nil, err
+	}
+	data, err := templateData(h, Email{})
+	if err != nil {
+		return nil, err
+	}
+	for _, tplt := range []struct {
+		file    string
+		html    bool
+		execute func(context.Context, io.Writer, *template.Template, Template) error
+	}{
+		{manifest.HTML, true, executeHTML},
+		{manifest.PlainText, false, executePlainText},
+	} {
+		parsed, err := parseThemeTemplate(t, tplt.html, sandbox)
+		if err == nil {
+			err = tplt.execute(context.Background(), ioutil.Discard, parsed, data)
+		}
+		if err != nil {
+			return nil, themeError(tplt.file, err)
+		}
+	}
+	return t, nil
+}
+
+// Name returns the name of the theme, from its manifest
+func (t *fsTheme) Name() string {
+	return t.name
+}
+
+// HTMLTemplate returns the content of the HTML template file
+func (t *fsTheme) HTMLTemplate() string {
+	return t.html
+}
+
+// PlainTextTemplate returns the content of the plain text template file
+func (t *fsTheme) PlainTextTemplate() string {
+	return t.plainText
+}
+
+// Partials returns the partial templates, by name
+func (t *fsTheme) Partials() map[string]string {
+	return t.partials
+}
+
+// Stylesheet returns the content of the stylesheet file, if any
+func (t *fsTheme) Stylesheet() string {
+	return t.stylesheet
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ExtendedTheme is a theme overriding some named blocks of a base theme, and inheriting the rest
+// Bundled themes define the blocks masthead, greeting, intros, dictionary, table, actions, outros, signature,
+// trouble-links, footer and dark-mode in their HTML template, and the same blocks but masthead, trouble-links and dark-mode
+// in their plain text template.
+//
+//	theme := &hermes.ExtendedTheme{
+//		Base:      new(hermes.Default),
+//		ThemeName: "mydefault",
+//		HTML:      `{{ define "footer" }}<p class="sub center">Sent with love</p>{{ end }}`,
+//	}
+type ExtendedTheme struct {
+	Base      Theme  // Theme inherited from
+	ThemeName string // Name of the theme (default to the name of Base)
+	HTML      string // {{define}} actions overriding blocks of the HTML template of Base
+	PlainText string // {{define}} actions overriding blocks of the plain text template of Base
+}
+
+// Name returns the name of the theme
+func (t *ExtendedTheme) Name() string {
+	if t.ThemeName != "" {
+		return t.ThemeName
+	}
+	return t.Base.Name()
+}
+
+// HTMLTemplate returns the HTML template of the base theme, before overrides
+func (t *ExtendedTheme) HTMLTemplate() string {
+	return t.Base.HTMLTemplate()
+}
+
+// PlainTextTemplate returns the plain text template of the base theme, before overrides
+func (t *ExtendedTheme) PlainTextTemplate() string {
+	return t.Base.PlainTextTemplate()
+}
+
+// Partials returns the partial templates of the base theme, if any
+func (t *ExtendedTheme) Partials() map[string]string {
+	if partials, ok := t.Base.(PartialsTheme); ok {
+		return partials.Partials()
+	}
+	return nil
+}
+
+// DefaultOptions returns the default options of the base theme, if any
+func (t *ExtendedTheme) DefaultOptions() ThemeOptions {
+	if options, ok := t.Base.(OptionsTheme); ok {
+		return options.DefaultOptions()
+	}
+	return ThemeOptions{}
+}
+
+// Stylesheet returns the stylesheet of the base theme, if any
+func (t *ExtendedTheme) Stylesheet() string {
+	if stylesheet, ok := t.Base.(StylesheetTheme); ok {
+		return stylesheet.Stylesheet()
+	}
+	return ""
+}
+
+// overrides returns the overrides of the HTML or plain text template, from the furthest base theme to t
+func (t *ExtendedTheme) overrides(html bool) []string {
+	var overrides []string
+	if base, ok := t.Base.(*ExtendedTheme); ok {
+		overrides = base.overrides(html)
+	}
+	if html {
+		return append(overrides, t.HTML)
+	}
+	return append(overrides, t.PlainText)
+}
+
+// overrideBlocks parses overrides of blocks on top of a parsed template
+// Each override can only redefine blocks already defined by the template.
+func overrideBlocks(t *template.Template, overrides []string, sandbox *Sandbox) error {
+	for _, override := range overrides {
+		parsed, err := parseTemplate(override, sandbox)
+		if err != nil {
+			return fmt.Errorf("hermes: invalid override: %v", err)
+		}
+		if parsed.Tree != nil && strings.TrimSpace(parsed.Tree.Root.String()) != "" {
+			return fmt.Errorf("hermes: invalid override: only {{define}} actions are allowed, got %q", strings.TrimSpace(parsed.Tree.Root.String()))
+		}
+		for _, block := range parsed.Templates() {
+			if block.Name() != parsed.Name() && t.Lookup(block.Name()) == nil {
+				return fmt.Errorf("hermes: invalid override: block %q is not defined by the theme", block.Name())
+			}
+		}
+		_, err = t.Parse(override)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Themes returns the themes bundled with hermes
+func Themes() []Theme {
+	return []Theme{
+		new(Default),
+		new(Flat),
+	}
+}
+
+// ThemeByName returns the bundled theme with the given name
+func ThemeByName(name string) (Theme, error) {
+	return findTheme(Themes(), name)
+}
+
+// findTheme returns the theme with the given name among themes
+func findTheme(themes []Theme, name string) (Theme, error) {
+	for _, t := range themes {
+		if t.Name() == name {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("hermes: unknown theme %q", name)
+}
@@ -0,0 +1,289 @@
+package hermes
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThemeByName(t *testing.T) {
+	for _, theme := range Themes() {
+		found, err := ThemeByName(theme.Name())
+		assert.Nil(t, err)
+		assert.Equal(t, theme, found)
+	}
+	_, err := ThemeByName("unknown")
+	assert.NotNil(t, err)
+}
+
+func TestThemeFromFS(t *testing.T) {
+	theme, err := ThemeFromFS(os.DirFS("testdata/themes"), "simple")
+	assert.Nil(t, err)
+	assert.Equal(t, "simple", theme.Name())
+	assert.Contains(t, theme.(interface{ Stylesheet() string }).Stylesheet(), ".greeting { color: #22BC66; }")
+
+	h := Hermes{Theme: theme, Product: Product{Copyright: "Copyright © Hermes"}}
+	email := Email{Body: Body{Name: "Jon Snow", Intros: []string{"Welcome to Hermes!"}}}
+	res, err := h.GenerateHTML(email)
+	assert.Nil(t, err)
+	assert.Contains(t, res, `<h1 class="greeting" style="color:#22BC66">Hi Jon Snow,`, "Partials and stylesheet should be used")
+	assert.Contains(t, res, `<p class="footer" style="font-size:12px">Copyright © Hermes</p>`)
+	assert.Contains(t, res, "<p>Welcome to Hermes!</p>")
+
+	text, err := h.GeneratePlainText(email)
+	assert.Nil(t, err)
+	assert.Contains(t, text, "Hi Jon Snow,")
+	assert.Contains(t, text, "Copyright © Hermes")
+}
+
+func TestThemeFromFS_Defaults(t *testing.T) {
+	fsys := fstest.MapFS{
+		"themes/minimal/theme.yaml": {Data: []byte("")},
+		"themes/minimal/html.tmpl":  {Data: []byte("<p>{{ .Email.Body.Name }}</p>")},
+		"themes/minimal/text.tmpl":  {Data: []byte("{{ .Email.Body.Name }}")},
+	}
+	theme, err := ThemeFromFS(fsys, "themes/minimal")
+	assert.Nil(t, err)
+	assert.Equal(t, "minimal", theme.Name(), "Name should default to the name of the directory")
+	assert.Empty(t, theme.(PartialsTheme).Partials())
+
+	_, err = ThemeFromFS(fsys, "themes/unknown")
+	assert.NotNil(t, err)
+}
+
+func TestThemeFromFS_Invalid(t *testing.T) {
+	valid := fstest.MapFS{
+		"theme.yaml":           {Data: []byte("name: invalid\nstylesheet: style.css\npartials: [partials/*.tmpl]")},
+		"html.tmpl":            {Data: []byte(`<style>{{ template "stylesheet" . }}</style>{{ template "footer" . }}`)},
+		"text.tmpl":            {Data: []byte(`{{ template "footer" . }}`)},
+		"style.css":            {Data: []byte("p { color: red; }")},
+		"partials/footer.tmpl": {Data: []byte("<p>{{ .Hermes.Product.Name }}</p>")},
+	}
+	_, err := ThemeFromFS(valid, ".")
+	assert.Nil(t, err)
+
+	for _, test := range []struct {
+		file    string
+		content string
+		errFile string
+	}{
+		{"theme.yaml", "name: invalid\nhtmlTemplate: index.html", "theme.yaml"},
+		{"theme.yaml", "partials: [partials/*.tmpl]", "theme.yaml"},
+		{"theme.yaml", "name: invalid\npartials: [layouts/*.tmpl]", "theme.yaml"},
+		{"theme.yaml", "name: invalid\nhtml: index.html", "index.html"},
+		{"html.tmpl", "<p>{{ .Email.Body.Name </p>", "html.tmpl"},
+		{"html.tmpl", `{{ template "header" . }}`, "html.tmpl"},
+		{"text.tmpl", "{{ .Email.Body.Unknown }}", "text.tmpl"},
+		{"style.css", "p { color: {{ red; }", "style.css"},
+		{"partials/footer.tmpl", "{{ end }}", "partials/footer.tmpl"},
+		{"partials/stylesheet.tmpl", "p {}", "partials/stylesheet.tmpl"},
+	} {
+		fsys := fstest.MapFS{}
+		for name, file := range valid {
+			fsys[name] = file
+		}
+		fsys[test.file] = &fstest.MapFile{Data: []byte(test.content)}
+		_, err := ThemeFromFS(fsys, ".")
+		assert.NotNil(t, err, "Theme should be invalid with %s: %s", test.file, test.content)
+		themeErr, ok := err.(*ThemeError)
+		if assert.True(t, ok, "Error should be a ThemeError: %v", err) {
+			assert.Equal(t, test.errFile, themeErr.File, "Error should be reported on the invalid file: %v", err)
+		}
+	}
+}
+
+func TestThemeFromFS_OutsideDirectory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"secrets/db.txt":           {Data: []byte("password")},
+		"uploads/evil/html.tmpl":   {Data: []byte("<p>{{ .Email.Body.Name }}</p>")},
+		"uploads/evil/text.tmpl":   {Data: []byte("{{ .Email.Body.Name }}")},
+		"uploads/other/text.tmpl":  {Data: []byte("{{ .Email.Body.Name }}")},
+		"uploads/evil/footer.tmpl": {Data: []byte("<p>{{ .Hermes.Product.Name }}</p>")},
+	}
+	fsys["uploads/evil/theme.yaml"] = &fstest.MapFile{Data: []byte("name: evil\nhtml: ./html.tmpl\npartials: [./*.tmpl]")}
+	_, err := Sandbox{}.ThemeFromFS(fsys, "uploads/evil")
+	assert.Nil(t, err)
+
+	for _, manifest := range []string{
+		"name: evil\nhtml: ../../secrets/db.txt",
+		"name: evil\nplainText: ../other/text.tmpl",
+		"name: evil\nstylesheet: /secrets/db.txt",
+		"name: evil\nhtml: html.tmpl/../../../secrets/db.txt",
+		"name: evil\npartials: [../../secrets/*.txt]",
+		"name: evil\npartials: [/secrets/*]",
+	} {
+		fsys["uploads/evil/theme.yaml"] = &fstest.MapFile{Data: []byte(manifest)}
+		theme, err := Sandbox{}.ThemeFromFS(fsys, "uploads/evil")
+		assert.Nil(t, theme)
+		_, ok := err.(*ThemeError)
+		assert.True(t, ok, "Files outside of the theme directory should not be read with %q: %v", manifest, err)
+	}
+}
+
+func TestBundledThemesBlocks(t *testing.T) {
+	for _, theme := range Themes() {
+		html, err := parseThemeTemplate(theme, true, nil)
+		assert.Nil(t, err)
+		for _, block := range []string{"masthead", "greeting", "intros", "dictionary", "table", "actions", "outros", "signature", "trouble-links", "footer", "dark-mode"} {
+			assert.NotNil(t, html.Lookup(block), "HTML template of %s should define block %s", theme.Name(), block)
+		}
+		plainText, err := parseThemeTemplate(theme, false, nil)
+		assert.Nil(t, err)
+		for _, block := range []string{"greeting", "intros", "dictionary", "table", "actions", "outros", "signature", "footer"} {
+			assert.NotNil(t, plainText.Lookup(block), "Plain text template of %s should define block %s", theme.Name(), block)
+		}
+	}
+}
+
+func TestExtendedTheme(t *testing.T) {
+	for _, base := range Themes() {
+		h, email := (&SimpleExample{base}).getExample()
+		expected, err := h.Generate(email)
+		assert.Nil(t, err)
+
+		h.Theme = &ExtendedTheme{Base: base}
+		inherited, err := h.Generate(email)
+		assert.Nil(t, err)
+		assert.Equal(t, expected.HTML, inherited.HTML, "Theme without overrides should render as its base")
+		assert.Equal(t, expected.Text, inherited.Text)
+		assert.Equal(t, base.Name(), inherited.Theme)
+
+		h.Theme = &ExtendedTheme{
+			Base:      base,
+			ThemeName: "custom",
+			HTML: `
+				{{ define "footer" }}<p class="custom-footer">Sent by {{ .Hermes.Product.Name }}</p>{{ end }}
+				{{ define "signature" }}<p>Cheers</p>{{ end }}`,
+			PlainText: `{{ define "footer" }}<p>Sent by {{ .Hermes.Product.Name }}</p>{{ end }}`,
+		}
+		overridden, err := h.Generate(email)
+		assert.Nil(t, err)
+		assert.Equal(t, "custom", overridden.Theme)
+		assert.Contains(t, overridden.HTML, `<p class="custom-footer">Sent by HermesName</p>`)
+		assert.Contains(t, overridden.HTML, "<p>Cheers</p>")
+		assert.NotContains(t, overridden.HTML, "Copyright © 2017-2017 Hermes.")
+		assert.NotContains(t, overridden.HTML, "Yours truly")
+		assert.Contains(t, overridden.HTML, `class="email-masthead"`, "Other blocks should be inherited")
+		assert.Contains(t, overridden.HTML, "Welcome to Hermes! We&#39;re very excited to have you on board.")
+		assert.Contains(t, overridden.Text, "Sent by HermesName")
+		assert.Contains(t, overridden.Text, "Yours truly", "Plain text signature should be inherited")
+		assert.NotContains(t, overridden.Text, "Copyright © 2017-2017 Hermes.")
+
+		// Themes extending an extended theme inherit its overrides
+		h.Theme = &ExtendedTheme{
+			Base: h.Theme,
+			HTML: `{{ define "greeting" }}<h1>Welcome {{ .Email.Body.Name }}</h1>{{ end }}`,
+		}
+		nested, err := h.Generate(email)
+		assert.Nil(t, err)
+		assert.Equal(t, "custom", nested.Theme)
+		assert.Contains(t, nested.HTML, "<h1>Welcome Jon Snow</h1>")
+		assert.Contains(t, nested.HTML, `<p class="custom-footer">Sent by HermesName</p>`)
+	}
+}
+
+func TestExtendedTheme_Invalid(t *testing.T) {
+	for _, override := range []string{
+		`{{ define "unknown" }}<p>Unknown block</p>{{ end }}`,
+		`<p>Content outside blocks</p>`,
+		`{{ define "footer" }}{{ .Hermes.Product.Name }`,
+	} {
+		_, err := NewRenderer(Hermes{Theme: &ExtendedTheme{Base: new(Default), HTML: override}})
+		assert.NotNil(t, err, "Override should be invalid: %s", override)
+	}
+}
+
+func TestBundledThemesStylesheet(t *testing.T) {
+	for _, theme := range Themes() {
+		stylesheet, ok := theme.(StylesheetTheme)
+		assert.True(t, ok, "Theme %s should expose its stylesheet", theme.Name())
+		assert.Contains(t, stylesheet.Stylesheet(), ".button {")
+		assert.NotContains(t, theme.HTMLTemplate(), ".button {", "Stylesheet should not be in the markup of %s", theme.Name())
+
+		extended := &ExtendedTheme{Base: theme, HTML: `{{ define "stylesheet" }}p { color: red; }{{ end }}`}
+		assert.Equal(t, stylesheet.Stylesheet(), extended.Stylesheet())
+		res, err := (&Hermes{Theme: extended, DisableCSSInlining: true}).GenerateHTML(Email{})
+		assert.Nil(t, err)
+		assert.Regexp(t, `media="all">\s*p { color: red; }\s*</style>`, res, "Stylesheet should be overridable as a block")
+		assert.NotContains(t, res, ".button {")
+	}
+}
+
+func TestThemeOptions(t *testing.T) {
+	for _, theme := range Themes() {
+		h := Hermes{Theme: theme, DisableCSSInlining: true}
+		email := Email{Body{Actions: []Action{{Button: Button{Text: "Confirm", Link: "https://hermes-example.com/confirm"}}}}}
+		res, err := h.GenerateHTML(email)
+		assert.Nil(t, err)
+		assert.Contains(t, res, "font-family: Arial, 'Helvetica Neue', Helvetica, sans-serif;", "Defaults of %s should be written as is", theme.Name())
+		assert.Contains(t, res, `width="570"`)
+
+		h.ThemeOptions = ThemeOptions{
+			PrimaryColor: "rgb(255, 87, 34)",
+			FontFamily:   "Georgia, serif",
+			ContentWidth: 640,
+			BorderRadius: -1,
+		}
+		res, err = h.GenerateHTML(email)
+		assert.Nil(t, err)
+		assert.Contains(t, res, "background-color: rgb(255, 87, 34);")
+		assert.Contains(t, res, `fillcolor="rgb(255, 87, 34)"`)
+		assert.Contains(t, res, "font-family: Georgia, serif;")
+		assert.Contains(t, res, `width="640"`)
+		assert.Contains(t, res, "width: 640px;")
+		assert.Contains(t, res, "border-radius: 0px;")
+		assert.Contains(t, res, "line-height: 45px;", "Zero options should default to the ones of %s", theme.Name())
+		assert.Equal(t, ThemeOptions{PrimaryColor: "rgb(255, 87, 34)", FontFamily: "Georgia, serif", ContentWidth: 640, BorderRadius: -1}, h.ThemeOptions, "Options of hermes should not be modified")
+
+		h.ThemeOptions = ThemeOptions{TextColor: "red</style><script>alert(1)</script>"}
+		_, err = h.GenerateHTML(email)
+		assert.EqualError(t, err, "hermes: themeOptions.textColor: is not a valid CSS value")
+	}
+
+	extended := &ExtendedTheme{Base: new(Flat)}
+	assert.Equal(t, new(Flat).DefaultOptions(), extended.DefaultOptions())
+}
+
+func TestHermes_DarkMode(t *testing.T) {
+	logo, err := ReadFile("examples/gopher.png")
+	assert.Nil(t, err)
+	darkLogo := &File{Name: "gopher-dark.png", Content: logo.Content}
+
+	for _, theme := range Themes() {
+		h := Hermes{Theme: theme, Product: Product{Name: "Hermes", Logo: "https://hermes-example.com/logo.png"}}
+		res, err := h.GenerateHTML(Email{})
+		assert.Nil(t, err)
+		assert.Contains(t, res, `<meta name="color-scheme" content="light dark"/>`)
+		assert.Contains(t, res, "@media (prefers-color-scheme: dark)", "Dark mode styles of %s should be kept by CSS inlining", theme.Name())
+		assert.Contains(t, res, "[data-ogsc] h1,", "Outlook.com styles of %s should be kept by CSS inlining", theme.Name())
+		assert.NotContains(t, res, `class="dark-img"`)
+
+		h.Product.DarkLogo = "https://hermes-example.com/logo-dark.png"
+		h.ThemeOptions.DarkBackgroundColor = "#000000"
+		res, err = h.GenerateHTML(Email{})
+		assert.Nil(t, err)
+		assert.Regexp(t, `<img src="https://hermes-example.com/logo.png" class="email-logo light-img"`, res)
+		assert.Regexp(t, `<div class="dark-img" style="[^"]*display:none[^"]*"><img src="https://hermes-example.com/logo-dark.png"`, res, "Dark logo should be hidden outside of dark mode")
+		assert.Contains(t, res, "background-color: #000000 !important;")
+
+		h.Product.LogoFile, h.Product.DarkLogoFile = logo, darkLogo
+		rendered, err := h.Generate(Email{})
+		assert.Nil(t, err)
+		assert.Contains(t, rendered.HTML, `<img src="cid:gopher-dark.png"`)
+		assert.Equal(t, []*File{logo, darkLogo}, rendered.Images)
+
+		h.ThemeOptions.DisableDarkMode = true
+		res, err = h.GenerateHTML(Email{})
+		assert.Nil(t, err)
+		assert.NotContains(t, res, "color-scheme")
+
+		h.ThemeOptions.DisableDarkMode = false
+		h.Theme = &ExtendedTheme{Base: theme, HTML: `{{ define "dark-mode" }}<style data-premailer="ignore">@media (prefers-color-scheme: dark) { h1 { color: red; } }</style>{{ end }}`}
+		res, err = h.GenerateHTML(Email{})
+		assert.Nil(t, err)
+		assert.Contains(t, res, "h1 { color: red; }", "Dark mode styles should be overridable as a block")
+		assert.NotContains(t, res, "[data-ogsc]")
+	}
+}
@@ -0,0 +1,74 @@
+package hermes
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultURLSchemes are the schemes allowed by a URLPolicy without schemes
+// cid is the scheme of images embedded in the email, e.g. Product.LogoFile.
+var DefaultURLSchemes = []string{"http", "https", "mailto", "tel", "cid"}
+
+// URLPolicy restricts the URLs of the links and images of emails: Product.Link, Product.Logo, Product.DarkLogo
+// and the links of action buttons, which themes output as they are.
+// The zero value allows relative URLs and URLs with DefaultURLSchemes on any host, and fails the generation otherwise.
+type URLPolicy struct {
+	Schemes []string // Allowed schemes, e.g. https (default to DefaultURLSchemes)
+	Hosts   []string // Allowed hosts of URLs, e.g. example.com, or *.example.com for its subdomains (default to any host)
+	Rewrite bool     // Replace disallowed URLs with "#" instead of failing the generation
+}
+
+// Check returns an error when rawURL is not allowed by the policy
+func (p URLPolicy) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("is not a valid URL")
+	}
+	schemes := p.Schemes
+	if len(schemes) == 0 {
+		schemes = DefaultURLSchemes
+	}
+	if u.Scheme != "" && !containsFold(schemes, u.Scheme) {
+		return fmt.Errorf("has a disallowed scheme %q", u.Scheme)
+	}
+	if u.Host != "" && len(p.Hosts) > 0 && !p.allowsHost(u.Hostname()) {
+		return fmt.Errorf("has a disallowed host %q", u.Hostname())
+	}
+	return nil
+}
+
+// allowsHost returns whether host is one of the allowed hosts or one of their subdomains, for wildcard ones
+func (p URLPolicy) allowsHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range p.Hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply checks the URLs of the product and the actions, rewriting the disallowed ones to "#" when enabled
+// Actions are modified in place.
+func (p URLPolicy) apply(errs *ValidationErrors, product *Product, actions []Action) {
+	check := func(field string, u *string) {
+		if *u == "" {
+			return
+		}
+		if err := p.Check(*u); err != nil {
+			if p.Rewrite {
+				*u = "#"
+			} else {
+				errs.add(field, "%v", err)
+			}
+		}
+	}
+	check("product.link", &product.Link)
+	check("product.logo", &product.Logo)
+	check("product.darkLogo", &product.DarkLogo)
+	for i := range actions {
+		check(fmt.Sprintf("body.actions[%d].button.link", i), &actions[i].Button.Link)
+	}
+}
@@ -0,0 +1,87 @@
+package hermes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLPolicy_Check(t *testing.T) {
+	tests := []struct {
+		policy URLPolicy
+		url    string
+		valid  bool
+	}{
+		{URLPolicy{}, "https://hermes-example.com/confirm", true},
+		{URLPolicy{}, "HTTPS://hermes-example.com/confirm", true},
+		{URLPolicy{}, "mailto:support@hermes-example.com", true},
+		{URLPolicy{}, "cid:logo.png", true},
+		{URLPolicy{}, "/confirm?token=abc", true},
+		{URLPolicy{}, "javascript:alert(1)", false},
+		{URLPolicy{}, "JavaScript:alert(1)", false},
+		{URLPolicy{}, " javascript:alert(1)", false},
+		{URLPolicy{}, "java\tscript:alert(1)", false},
+		{URLPolicy{}, "data:text/html;base64,PHNjcmlwdD4=", false},
+		{URLPolicy{Schemes: []string{"https", "data"}}, "data:image/png;base64,iVBORw0KGgo=", true},
+		{URLPolicy{Schemes: []string{"https"}}, "http://hermes-example.com", false},
+		{URLPolicy{Hosts: []string{"hermes-example.com"}}, "https://hermes-example.com/confirm", true},
+		{URLPolicy{Hosts: []string{"hermes-example.com"}}, "https://evil.com/confirm", false},
+		{URLPolicy{Hosts: []string{"hermes-example.com"}}, "//evil.com/confirm", false},
+		{URLPolicy{Hosts: []string{"hermes-example.com"}}, "https://hermes-example.com.evil.com", false},
+		{URLPolicy{Hosts: []string{"hermes-example.com"}}, "https://www.hermes-example.com", false},
+		{URLPolicy{Hosts: []string{"*.hermes-example.com"}}, "https://www.Hermes-Example.com", true},
+		{URLPolicy{Hosts: []string{"*.hermes-example.com"}}, "https://evilhermes-example.com", false},
+		{URLPolicy{Hosts: []string{"hermes-example.com"}}, "mailto:support@evil.com", true},
+	}
+	for _, test := range tests {
+		err := test.policy.Check(test.url)
+		if test.valid {
+			assert.Nil(t, err, "%q should be allowed by %+v", test.url, test.policy)
+		} else {
+			assert.NotNil(t, err, "%q should not be allowed by %+v", test.url, test.policy)
+		}
+	}
+}
+
+func TestHermes_URLPolicy(t *testing.T) {
+	email := Email{Body{Actions: []Action{{Button: Button{Text: "Confirm", Link: "javascript:alert(document.cookie)"}}}}}
+	for _, theme := range Themes() {
+		h := Hermes{Theme: theme, Product: Product{Logo: "javascript:alert(1)", Link: "https://hermes-example.com"}}
+		_, err := h.Generate(email)
+		errs, ok := err.(ValidationErrors)
+		if assert.True(t, ok, "Error should be a ValidationErrors: %v", err) && assert.Len(t, errs, 2) {
+			assert.Equal(t, "product.logo", errs[0].Field)
+			assert.Equal(t, `has a disallowed scheme "javascript"`, errs[0].Message)
+			assert.Equal(t, "body.actions[0].button.link", errs[1].Field)
+		}
+
+		h.URLPolicy.Rewrite = true
+		rendered, err := h.Generate(email)
+		assert.Nil(t, err)
+		assert.NotContains(t, rendered.HTML, "javascript:", "Disallowed URLs of %s should be rewritten", theme.Name())
+		assert.NotContains(t, rendered.Text, "javascript:", "Disallowed URLs of %s should be rewritten", theme.Name())
+		assert.Contains(t, rendered.HTML, `src="#"`)
+		assert.Contains(t, rendered.HTML, `href="https://hermes-example.com"`)
+		assert.Equal(t, "javascript:alert(document.cookie)", email.Body.Actions[0].Button.Link, "Email should not be modified")
+	}
+
+	logo, err := ReadFile("examples/gopher.png")
+	assert.Nil(t, err)
+	h := Hermes{Product: Product{LogoFile: logo}, URLPolicy: URLPolicy{Schemes: []string{"https"}}}
+	res, err := h.GenerateHTML(Email{})
+	assert.Nil(t, err)
+	assert.Contains(t, res, `src="cid:gopher.png"`, "Inline images should not be checked")
+}
+
+func TestHermes_URLPolicyOtherSchemes(t *testing.T) {
+	email := Email{Body{Actions: []Action{{Button: Button{Text: "Call us", Link: "tel:+33123456789"}}}}}
+	for _, theme := range Themes() {
+		h := Hermes{Theme: theme, Product: Product{Link: "mailto:support@hermes-example.com"}}
+		res, err := h.GenerateHTML(email)
+		assert.Nil(t, err)
+		assert.NotContains(t, res, "ZgotmplZ", "Allowed URLs of %s should not be filtered", theme.Name())
+		assert.Equal(t, 3, strings.Count(res, `href="tel:+33123456789"`), "Button links of %s should be kept", theme.Name())
+		assert.Contains(t, res, `href="mailto:support@hermes-example.com"`)
+	}
+}
@@ -0,0 +1,142 @@
+package hermes
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidationError is an invalid field of an email
+type ValidationError struct {
+	Field   string `json:"field"`   // Path of the invalid field, e.g. body.actions[0].button.link
+	Message string `json:"message"` // Why the field is invalid
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors are all the invalid fields of an email
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Error()
+	}
+	return "hermes: invalid email: " + strings.Join(messages, "; ")
+}
+
+// add adds an error on the given field
+func (errs *ValidationErrors) add(field string, format string, args ...interface{}) {
+	*errs = append(*errs, &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks that the email can be rendered as expected, before generating it
+// Returned error is a ValidationErrors listing all the invalid fields, or nil.
+func (e Email) Validate() error {
+	var errs ValidationErrors
+	e.Body.validate(&errs, "body")
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (b Body) validate(errs *ValidationErrors, field string) {
+	for i, entry := range b.Dictionary {
+		if entry.Key == "" {
+			errs.add(fmt.Sprintf("%s.dictionary[%d].key", field, i), "is required")
+		}
+	}
+	b.Table.validate(errs, field+".table")
+	for i, action := range b.Actions {
+		action.validate(errs, fmt.Sprintf("%s.actions[%d]", field, i))
+	}
+	ids := make(map[string]bool)
+	for i, image := range b.Images {
+		f := fmt.Sprintf("%s.images[%d]", field, i)
+		if image == nil {
+			errs.add(f, "is required")
+			continue
+		}
+		if err := validateImage(image); err != nil {
+			errs.add(f, "%s", strings.TrimPrefix(err.Error(), "hermes: "))
+		} else if ids[image.ContentID()] {
+			errs.add(f+".name", "is already used by another image")
+		}
+		ids[image.ContentID()] = true
+	}
+}
+
+func (t Table) validate(errs *ValidationErrors, field string) {
+	if len(t.Data) == 0 {
+		return
+	}
+	// First row defines the columns of the table
+	columns := make(map[string]bool)
+	for i, entry := range t.Data[0] {
+		if entry.Key == "" {
+			errs.add(fmt.Sprintf("%s.data[0][%d].key", field, i), "is required")
+		}
+		columns[entry.Key] = true
+	}
+	for i, row := range t.Data[1:] {
+		if len(row) != len(t.Data[0]) {
+			errs.add(fmt.Sprintf("%s.data[%d]", field, i+1), "has %d columns instead of %d", len(row), len(t.Data[0]))
+			continue
+		}
+		for j, entry := range row {
+			if entry.Key != t.Data[0][j].Key {
+				errs.add(fmt.Sprintf("%s.data[%d][%d].key", field, i+1, j), "is %q instead of %q", entry.Key, t.Data[0][j].Key)
+			}
+		}
+	}
+	for _, c := range []struct {
+		name   string
+		values map[string]string
+	}{
+		{"customWidth", t.Columns.CustomWidth},
+		{"customAlignment", t.Columns.CustomAlignment},
+	} {
+		for column := range c.values {
+			if !columns[column] {
+				errs.add(fmt.Sprintf("%s.columns.%s[%q]", field, c.name, column), "is not a column of the table")
+			}
+		}
+	}
+}
+
+// validateColors checks the colors of the button, output by themes as they are
+func (b Button) validateColors(errs *ValidationErrors, field string) {
+	for _, color := range []struct {
+		name  string
+		value string
+	}{
+		{"color", b.Color},
+		{"textColor", b.TextColor},
+	} {
+		if color.value != "" && !validColor(color.value) {
+			errs.add(field+"."+color.name, "is not a valid color")
+		}
+	}
+}
+
+func (a Action) validate(errs *ValidationErrors, field string) {
+	if a.InviteCode != "" {
+		return
+	}
+	if a.Button.Text == "" && a.Button.Link == "" {
+		errs.add(field, "needs a button or an invite code")
+		return
+	}
+	if a.Button.Text == "" {
+		errs.add(field+".button.text", "is required")
+	}
+	a.Button.validateColors(errs, field+".button")
+	if a.Button.Link == "" {
+		errs.add(field+".button.link", "is required")
+	} else if _, err := url.Parse(a.Button.Link); err != nil {
+		errs.add(field+".button.link", "is not a valid URL")
+	}
+}
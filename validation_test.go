@@ -0,0 +1,72 @@
+package hermes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmail_ValidateExamples(t *testing.T) {
+	for _, ex := range []Example{
+		&SimpleExample{new(Default)},
+		&WithTitleInsteadOfNameExample{new(Default)},
+		&WithInviteCode{new(Default)},
+		&WithFreeMarkdownContent{new(Default)},
+	} {
+		_, email := ex.getExample()
+		assert.Nil(t, email.Validate())
+	}
+	assert.Nil(t, Email{}.Validate(), "Empty email should be valid")
+}
+
+func TestEmail_Validate(t *testing.T) {
+	email := Email{
+		Body: Body{
+			Dictionary: []Entry{{Key: "Firstname", Value: "Jon"}, {Value: "Snow"}},
+			Table: Table{
+				Data: [][]Entry{
+					{{Key: "Item", Value: "Golang"}, {Key: "Price", Value: "$10.99"}},
+					{{Key: "Item", Value: "Hermes"}},
+					{{Key: "Item", Value: "Hermes"}, {Key: "Cost", Value: "$1.99"}},
+				},
+				Columns: Columns{CustomWidth: map[string]string{"Description": "20%"}},
+			},
+			Actions: []Action{
+				{Instructions: "Nothing to act on"},
+				{Button: Button{Text: "Confirm your account"}},
+				{Button: Button{Link: "https://hermes-example.com/confirm"}},
+				{Button: Button{Text: "Confirm", Link: "http://[::1"}},
+				{InviteCode: "123456"},
+				{Button: Button{Text: "Confirm", Link: "https://hermes-example.com/confirm", Color: "#22BC6", TextColor: "white"}},
+			},
+			Images: []*File{
+				{Name: "chart.png", Content: []byte("\x89PNG\r\n\x1a\n")},
+				{Name: "chart.png", Content: []byte("\x89PNG\r\n\x1a\n")},
+				{Name: "notes.txt", Content: []byte("Winter is coming")},
+			},
+		},
+	}
+	err := email.Validate()
+	assert.NotNil(t, err)
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok, "Should return ValidationErrors")
+
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{
+		"body.dictionary[1].key",
+		"body.table.data[1]",
+		"body.table.data[2][1].key",
+		`body.table.columns.customWidth["Description"]`,
+		"body.actions[0]",
+		"body.actions[1].button.link",
+		"body.actions[2].button.text",
+		"body.actions[3].button.link",
+		"body.actions[5].button.color",
+		"body.images[1].name",
+		"body.images[2]",
+	}, fields)
+	assert.Contains(t, err.Error(), "body.table.data[1]: has 1 columns instead of 2")
+}